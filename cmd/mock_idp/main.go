@@ -0,0 +1,248 @@
+// mock_idp - минимальный OpenID Connect провайдер для локальной проверки входа
+// через OIDC. Не требует пароля: пользователь и его группы задаются на странице
+// входа или параметрами username и groups запроса /authorize.
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"tspo_server/internal/auth"
+)
+
+type authCode struct {
+	clientID      string
+	redirectURI   string
+	nonce         string
+	codeChallenge string
+	username      string
+	groups        []string
+	expiresAt     time.Time
+}
+
+type server struct {
+	issuer       string // адрес, по которому IdP видят серверные клиенты
+	publicURL    string // адрес, по которому IdP видит браузер
+	clientID     string
+	clientSecret string
+	keys         *auth.KeySet
+	logger       *slog.Logger
+
+	mu    sync.Mutex
+	codes map[string]authCode
+}
+
+var loginPage = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html><body>
+<h1>Mock IdP</h1>
+<form method="GET" action="{{.Action}}">
+{{range $name, $values := .Params}}{{range $values}}<input type="hidden" name="{{$name}}" value="{{.}}">{{end}}{{end}}
+<p><label>Username <input name="username" value="alice"></label></p>
+<p><label>Groups (comma-separated) <input name="groups" value="librarians"></label></p>
+<p><button type="submit">Sign in</button></p>
+</form>
+</body></html>`))
+
+func main() {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	addr := getEnv("MOCK_IDP_ADDR", ":9000")
+	issuer := getEnv("MOCK_IDP_ISSUER", "http://localhost:9000")
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		logger.Error("failed to generate signing key", "error", err)
+		os.Exit(1)
+	}
+	keys, err := auth.NewKeySetFromSigner(key)
+	if err != nil {
+		logger.Error("failed to create key set", "error", err)
+		os.Exit(1)
+	}
+
+	s := &server{
+		issuer:       issuer,
+		publicURL:    getEnv("MOCK_IDP_PUBLIC_URL", issuer),
+		clientID:     getEnv("MOCK_IDP_CLIENT_ID", "tspo"),
+		clientSecret: os.Getenv("MOCK_IDP_CLIENT_SECRET"),
+		keys:         keys,
+		logger:       logger,
+		codes:        make(map[string]authCode),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("GET /authorize", s.authorize)
+	mux.HandleFunc("POST /token", s.token)
+	mux.HandleFunc("GET /jwks", s.jwks)
+
+	logger.Info("mock IdP started", "addr", addr, "issuer", issuer)
+	if err = http.ListenAndServe(addr, mux); err != nil {
+		logger.Error("server stopped", "error", err)
+	}
+}
+
+func (s *server) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                s.issuer,
+		"authorization_endpoint":                s.publicURL + "/authorize",
+		"token_endpoint":                        s.issuer + "/token",
+		"jwks_uri":                              s.issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (s *server) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != s.clientID || q.Get("redirect_uri") == "" || q.Get("response_type") != "code" {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+	if q.Get("code_challenge") == "" || q.Get("code_challenge_method") != "S256" {
+		http.Error(w, "PKCE with S256 is required", http.StatusBadRequest)
+		return
+	}
+
+	username := strings.TrimSpace(q.Get("username"))
+	if username == "" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		loginPage.Execute(w, map[string]interface{}{"Action": s.publicURL + "/authorize", "Params": q})
+		return
+	}
+
+	var groups []string
+	for _, group := range strings.Split(q.Get("groups"), ",") {
+		if group = strings.TrimSpace(group); group != "" {
+			groups = append(groups, group)
+		}
+	}
+
+	code := randomHex(16)
+	s.mu.Lock()
+	s.codes[code] = authCode{
+		clientID:      s.clientID,
+		redirectURI:   q.Get("redirect_uri"),
+		nonce:         q.Get("nonce"),
+		codeChallenge: q.Get("code_challenge"),
+		username:      username,
+		groups:        groups,
+		expiresAt:     time.Now().Add(time.Minute),
+	}
+	s.mu.Unlock()
+
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	params := redirect.Query()
+	params.Set("code", code)
+	params.Set("state", q.Get("state"))
+	redirect.RawQuery = params.Encode()
+
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (s *server) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeOAuthError(w, "unsupported_grant_type")
+		return
+	}
+
+	clientID, clientSecret, ok := r.BasicAuth()
+	if ok {
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != s.clientID || (s.clientSecret != "" && clientSecret != s.clientSecret) {
+		writeOAuthError(w, "invalid_client")
+		return
+	}
+
+	s.mu.Lock()
+	code, exists := s.codes[r.PostForm.Get("code")]
+	delete(s.codes, r.PostForm.Get("code"))
+	s.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !exists || time.Now().After(code.expiresAt) ||
+		code.redirectURI != r.PostForm.Get("redirect_uri") ||
+		code.codeChallenge != base64.RawURLEncoding.EncodeToString(sum[:]) {
+		writeOAuthError(w, "invalid_grant")
+		return
+	}
+
+	now := time.Now()
+	idToken, err := s.keys.Sign(jwt.MapClaims{
+		"iss":                s.issuer,
+		"sub":                "mock-" + code.username,
+		"aud":                code.clientID,
+		"exp":                now.Add(5 * time.Minute).Unix(),
+		"iat":                now.Unix(),
+		"nonce":              code.nonce,
+		"preferred_username": code.username,
+		"email":              code.username + "@example.com",
+		"email_verified":     true,
+		"groups":             code.groups,
+	})
+	if err != nil {
+		http.Error(w, "failed to sign id token", http.StatusInternalServerError)
+		return
+	}
+
+	s.logger.Info("issued id token", "username", code.username, "groups", code.groups)
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": randomHex(16),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func (s *server) jwks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.keys.JWKS())
+}
+
+func writeOAuthError(w http.ResponseWriter, code string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": code})
+}
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
+
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -15,6 +15,7 @@ import (
 	"tspo_server/internal/auth"
 	"tspo_server/internal/config"
 	"tspo_server/internal/db"
+	"tspo_server/internal/notify"
 	"tspo_server/pkg/logger"
 )
 
@@ -34,25 +35,243 @@ func main() {
 	defer database.Close()
 
 	repo, err := db.NewBookRepository(database)
+	if err != nil {
+		logger.Error("Failed to create book repository", slog.Any("error", err))
+		return
+	}
+
+	handler := app.NewHandler(repo, logger, c.RequireIfMatch)
 
-	handler := app.NewHandler(repo, logger)
+	trashJanitor := app.NewTrashJanitor(repo, c.TrashRetention, logger)
+	go trashJanitor.Run(context.Background(), c.TrashPurgeInterval)
+
+	authorRepo, err := db.NewAuthorRepository(database)
+	if err != nil {
+		logger.Error("Failed to create author repository", slog.Any("error", err))
+		return
+	}
+	authorHandler := app.NewAuthorHandler(authorRepo, repo, logger)
+
+	categoryRepo, err := db.NewCategoryRepository(database)
+	if err != nil {
+		logger.Error("Failed to create category repository", slog.Any("error", err))
+		return
+	}
+	categoryHandler := app.NewCategoryHandler(categoryRepo, logger)
 
 	mux := http.NewServeMux()
 
-	jwtMiddleware := auth.NewJWTMiddleware(c.JWTSecret, c.JWTRefreshSecret)
+	userRepo, err := db.NewUserRepository(database)
+	if err != nil {
+		logger.Error("Failed to create user repository", slog.Any("error", err))
+		return
+	}
+	sessionRepo, err := db.NewSessionRepository(database)
+	if err != nil {
+		logger.Error("Failed to create session repository", slog.Any("error", err))
+		return
+	}
+	revocationRepo, err := db.NewRevocationRepository(database)
+	if err != nil {
+		logger.Error("Failed to create revocation repository", slog.Any("error", err))
+		return
+	}
+	apiKeyRepo, err := db.NewAPIKeyRepository(database)
+	if err != nil {
+		logger.Error("Failed to create API key repository", slog.Any("error", err))
+		return
+	}
+	loginAttemptRepo, err := db.NewLoginAttemptRepository(database)
+	if err != nil {
+		logger.Error("Failed to create login attempt repository", slog.Any("error", err))
+		return
+	}
+	twoFactorRepo, err := db.NewTwoFactorRepository(database)
+	if err != nil {
+		logger.Error("Failed to create two-factor repository", slog.Any("error", err))
+		return
+	}
+	passwordResetRepo, err := db.NewPasswordResetRepository(database)
+	if err != nil {
+		logger.Error("Failed to create password reset repository", slog.Any("error", err))
+		return
+	}
+	verificationRepo, err := db.NewEmailVerificationRepository(database)
+	if err != nil {
+		logger.Error("Failed to create email verification repository", slog.Any("error", err))
+		return
+	}
+	identityRepo, err := db.NewIdentityRepository(database)
+	if err != nil {
+		logger.Error("Failed to create identity repository", slog.Any("error", err))
+		return
+	}
+
+	notifier, err := notify.New(notify.Config{
+		Kind:         c.Notifier,
+		File:         c.NotifierFile,
+		SMTPAddr:     c.SMTPAddr,
+		SMTPFrom:     c.SMTPFrom,
+		SMTPUsername: c.SMTPUsername,
+		SMTPPassword: c.SMTPPassword,
+	}, logger)
+	if err != nil {
+		logger.Error("Failed to configure notifier", slog.Any("error", err))
+		return
+	}
+
+	revocations := auth.NewRevocationList(revocationRepo, logger)
+	go revocations.RunJanitor(context.Background(), c.RevocationPruneInterval)
+
+	hasher, err := auth.NewPasswordHasher(auth.PasswordHashConfig{
+		Algorithm:      c.PasswordHashAlgorithm,
+		BcryptCost:     c.BcryptCost,
+		Argon2Time:     uint32(c.Argon2Time),
+		Argon2MemoryKB: uint32(c.Argon2MemoryKB),
+		Argon2Threads:  uint8(c.Argon2Threads),
+	})
+	if err != nil {
+		logger.Error("Invalid password hashing configuration", slog.Any("error", err))
+		return
+	}
+
+	var accessKeys *auth.KeySet
+	if c.JWTSigningKeyFile != "" {
+		accessKeys, err = auth.LoadKeySet(c.JWTSigningKeyFile, c.JWTVerificationKeyFiles)
+		if err != nil {
+			logger.Error("Failed to load JWT signing keys", slog.Any("error", err))
+			return
+		}
+	}
+
+	var oidcProvider *auth.OIDCProvider
+	if c.OIDCIssuerURL != "" {
+		roleMapping, err := auth.ParseRoleMapping(c.OIDCRoleMapping)
+		if err != nil {
+			logger.Error("Invalid OIDC role mapping", slog.Any("error", err))
+			return
+		}
+		oidcProvider, err = auth.NewOIDCProvider(context.Background(), auth.OIDCConfig{
+			IssuerURL:    c.OIDCIssuerURL,
+			ClientID:     c.OIDCClientID,
+			ClientSecret: c.OIDCClientSecret,
+			RedirectURL:  c.OIDCRedirectURL,
+			Scopes:       c.OIDCScopes,
+			GroupsClaim:  c.OIDCGroupsClaim,
+			RoleMapping:  roleMapping,
+		})
+		if err != nil {
+			logger.Error("Failed to configure OIDC provider", slog.Any("error", err))
+			return
+		}
+	}
+
+	jwtMiddleware := auth.NewJWTMiddleware(auth.Options{
+		AccessSecret:  c.JWTSecret,
+		AccessKeys:    accessKeys,
+		RefreshSecret: c.JWTRefreshSecret,
+		Tokens: auth.TokenConfig{
+			AccessTTL:  c.JWTAccessTTL,
+			RefreshTTL: c.JWTRefreshTTL,
+			Issuer:     c.JWTIssuer,
+			Audience:   c.JWTAudience,
+			ClockSkew:  c.JWTClockSkew,
+		},
+		UserStore:       userRepo,
+		Sessions:        sessionRepo,
+		APIKeys:         apiKeyRepo,
+		TwoFactor:       twoFactorRepo,
+		PasswordResets:  passwordResetRepo,
+		ResetTokenTTL:   c.PasswordResetTTL,
+		Verifications:   verificationRepo,
+		VerificationTTL: c.EmailVerificationTTL,
+		Notifier:        notifier,
+		Hasher:          hasher,
+		PasswordPolicy: auth.PasswordPolicy{
+			MinLength:     c.PasswordMinLength,
+			MaxLength:     c.PasswordMaxLength,
+			RequireUpper:  c.PasswordRequireUpper,
+			RequireLower:  c.PasswordRequireLower,
+			RequireDigit:  c.PasswordRequireDigit,
+			RequireSymbol: c.PasswordRequireSymbol,
+			Banned:        c.PasswordBanned,
+		},
+		Revocations: revocations,
+		OIDC:        oidcProvider,
+		Identities:  identityRepo,
+		LoginLimiter: auth.NewLoginLimiter(loginAttemptRepo, auth.LockoutConfig{
+			MaxUserFailures: c.LoginMaxUserFailures,
+			MaxIPFailures:   c.LoginMaxIPFailures,
+			Window:          c.LoginFailureWindow,
+			LockoutDuration: c.LoginLockoutDuration,
+			BaseDelay:       c.LoginBaseDelay,
+			MaxDelay:        c.LoginMaxDelay,
+		}, logger),
+		Logger: logger,
+	})
+
+	if c.AdminUsername != "" && c.AdminPassword != "" {
+		if err = jwtMiddleware.BootstrapAdmin(context.Background(), c.AdminUsername, c.AdminPassword); err != nil {
+			logger.Error("Failed to bootstrap admin account", slog.Any("error", err))
+			return
+		}
+	}
 
 	authMiddleware := app.NewAuthMiddleware(jwtMiddleware)
 
+	mux.HandleFunc("GET /.well-known/jwks.json", jwtMiddleware.JWKS)
 	mux.HandleFunc("POST /auth/register", jwtMiddleware.Register)
+	mux.HandleFunc("POST /auth/verify-email", jwtMiddleware.VerifyEmail)
+	mux.HandleFunc("POST /auth/verify-email/resend", jwtMiddleware.ResendVerification)
 	mux.HandleFunc("POST /auth/login", jwtMiddleware.Login)
+	mux.HandleFunc("POST /auth/2fa/login", jwtMiddleware.LoginTwoFactor)
+	mux.HandleFunc("GET /auth/oidc/login", jwtMiddleware.OIDCLogin)
+	mux.HandleFunc("GET /auth/oidc/callback", jwtMiddleware.OIDCCallback)
+	mux.HandleFunc("POST /auth/2fa/setup", authMiddleware.RequireAuth(jwtMiddleware.SetupTwoFactor))
+	mux.HandleFunc("POST /auth/2fa/verify", authMiddleware.RequireAuth(jwtMiddleware.VerifyTwoFactor))
 	mux.HandleFunc("POST /auth/refresh", jwtMiddleware.RefreshToken)
+	mux.HandleFunc("POST /auth/password", authMiddleware.RequireAuth(jwtMiddleware.ChangePassword))
+	mux.HandleFunc("POST /auth/password/forgot", jwtMiddleware.RequestPasswordReset)
+	mux.HandleFunc("POST /auth/password/reset", jwtMiddleware.ResetPassword)
 	mux.HandleFunc("POST /auth/logout", jwtMiddleware.Logout)
+	mux.HandleFunc("POST /auth/logout-all", authMiddleware.RequireAuth(jwtMiddleware.LogoutAll))
+	mux.HandleFunc("GET /auth/sessions", authMiddleware.RequireAuth(jwtMiddleware.ListSessions))
+	mux.HandleFunc("DELETE /auth/sessions/{id}", authMiddleware.RequireAuth(jwtMiddleware.RevokeSession))
+	mux.HandleFunc("POST /auth/api-keys", authMiddleware.RequireAuth(jwtMiddleware.CreateAPIKey))
+	mux.HandleFunc("GET /auth/api-keys", authMiddleware.RequireAuth(jwtMiddleware.ListAPIKeys))
+	mux.HandleFunc("DELETE /auth/api-keys/{id}", authMiddleware.RequireAuth(jwtMiddleware.RevokeAPIKey))
+
+	userHandler := app.NewUserHandler(userRepo, sessionRepo, logger)
+
+	mux.HandleFunc("POST /admin/unlock", authMiddleware.RequirePermission(auth.PermUsersManage, jwtMiddleware.UnlockLogin))
+	mux.HandleFunc("GET /admin/users", authMiddleware.RequirePermission(auth.PermUsersManage, userHandler.ListUsers))
+	mux.HandleFunc("GET /admin/users/{id}", authMiddleware.RequirePermission(auth.PermUsersManage, userHandler.GetUser))
+	mux.HandleFunc("PATCH /admin/users/{id}", authMiddleware.RequirePermission(auth.PermUsersManage, userHandler.UpdateUser))
+	mux.HandleFunc("DELETE /admin/users/{id}", authMiddleware.RequirePermission(auth.PermUsersManage, userHandler.DeleteUser))
 
 	mux.HandleFunc("GET /books", handler.GetBooks)
 	mux.HandleFunc("GET /books/{id}", handler.GetBook)
-	mux.HandleFunc("POST /books", handler.CreateBook)
-	mux.HandleFunc("PUT /books/{id}", handler.UpdateBook)
-	mux.HandleFunc("DELETE /books/{id}", handler.DeleteBook)
+	mux.HandleFunc("GET /books/trash", authMiddleware.RequirePermission(auth.PermBooksDelete, handler.GetTrash))
+	mux.HandleFunc("POST /books/{id}/restore", authMiddleware.RequirePermission(auth.PermBooksDelete, handler.RestoreBook))
+	mux.HandleFunc("GET /books/{id}/history", authMiddleware.RequirePermission(auth.PermBooksUpdate, handler.GetBookHistory))
+	mux.HandleFunc("GET /books/{id}/history/{version}", authMiddleware.RequirePermission(auth.PermBooksUpdate, handler.GetBookRevision))
+	mux.HandleFunc("POST /books", authMiddleware.RequirePermission(auth.PermBooksCreate, handler.CreateBook))
+	mux.HandleFunc("PUT /books/{id}", authMiddleware.RequirePermission(auth.PermBooksUpdate, handler.UpdateBook))
+	mux.HandleFunc("PATCH /books/{id}", authMiddleware.RequirePermission(auth.PermBooksUpdate, handler.PatchBook))
+	mux.HandleFunc("DELETE /books/{id}", authMiddleware.RequirePermission(auth.PermBooksDelete, handler.DeleteBook))
+
+	mux.HandleFunc("GET /authors", authorHandler.ListAuthors)
+	mux.HandleFunc("GET /authors/{id}", authorHandler.GetAuthor)
+	mux.HandleFunc("GET /authors/{id}/books", authorHandler.GetAuthorBooks)
+	mux.HandleFunc("POST /authors", authMiddleware.RequirePermission(auth.PermBooksCreate, authorHandler.CreateAuthor))
+	mux.HandleFunc("PUT /authors/{id}", authMiddleware.RequirePermission(auth.PermBooksUpdate, authorHandler.UpdateAuthor))
+	mux.HandleFunc("DELETE /authors/{id}", authMiddleware.RequirePermission(auth.PermBooksDelete, authorHandler.DeleteAuthor))
+
+	mux.HandleFunc("GET /categories", categoryHandler.ListCategories)
+	mux.HandleFunc("GET /categories/{id}", categoryHandler.GetCategory)
+	mux.HandleFunc("POST /categories", authMiddleware.RequirePermission(auth.PermBooksCreate, categoryHandler.CreateCategory))
+	mux.HandleFunc("PUT /categories/{id}", authMiddleware.RequirePermission(auth.PermBooksUpdate, categoryHandler.UpdateCategory))
+	mux.HandleFunc("DELETE /categories/{id}", authMiddleware.RequirePermission(auth.PermBooksDelete, categoryHandler.DeleteCategory))
 
 	mux.HandleFunc("GET /books_with_auth", authMiddleware.RequireAuth(handler.GetBooks))
 
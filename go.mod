@@ -3,7 +3,12 @@ module tspo_server
 go 1.23
 
 require (
+	github.com/evanphx/json-patch/v5 v5.9.11
 	github.com/golang-jwt/jwt/v5 v5.2.1
+	github.com/google/uuid v1.6.0
 	github.com/lib/pq v1.10.9
 	github.com/mdobak/go-xerrors v0.3.1
+	golang.org/x/crypto v0.28.0
 )
+
+require golang.org/x/sys v0.26.0 // indirect
@@ -2,13 +2,241 @@ CREATE TABLE IF NOT EXISTS books (
      id VARCHAR(36) PRIMARY KEY,
      title VARCHAR(255) NOT NULL,
      author VARCHAR(255) NOT NULL,
+     isbn_10 VARCHAR(10),
+     isbn_13 VARCHAR(13),
+     publisher VARCHAR(255),
+     publication_year INTEGER,
+     language VARCHAR(35),
+     page_count INTEGER CHECK (page_count > 0),
+     description TEXT,
+     edition VARCHAR(64),
+     tags TEXT[] NOT NULL DEFAULT '{}',
+     version INTEGER NOT NULL DEFAULT 1,
+     deleted_at TIMESTAMP WITH TIME ZONE, -- книга в корзине, если задано
+     created_by VARCHAR(255),
      created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
      updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 );
 
+-- Столбцы, появившиеся после первой версии схемы. CREATE TABLE IF NOT EXISTS не меняет
+-- существующую таблицу, поэтому для уже развёрнутой базы они добавляются здесь;
+-- скрипт можно выполнить повторно: psql -f init.sql
+ALTER TABLE books
+     ADD COLUMN IF NOT EXISTS isbn_10 VARCHAR(10),
+     ADD COLUMN IF NOT EXISTS isbn_13 VARCHAR(13),
+     ADD COLUMN IF NOT EXISTS publisher VARCHAR(255),
+     ADD COLUMN IF NOT EXISTS publication_year INTEGER,
+     ADD COLUMN IF NOT EXISTS language VARCHAR(35),
+     ADD COLUMN IF NOT EXISTS page_count INTEGER CHECK (page_count > 0),
+     ADD COLUMN IF NOT EXISTS description TEXT,
+     ADD COLUMN IF NOT EXISTS edition VARCHAR(64),
+     ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}',
+     ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1,
+     ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE,
+     ADD COLUMN IF NOT EXISTS created_by VARCHAR(255);
+
 -- Create index for sorting and filtering
 CREATE INDEX IF NOT EXISTS idx_books_title ON books(title);
 CREATE INDEX IF NOT EXISTS idx_books_author ON books(author);
+CREATE INDEX IF NOT EXISTS idx_books_isbn_13 ON books(isbn_13);
+CREATE INDEX IF NOT EXISTS idx_books_tags ON books USING GIN (tags);
+CREATE INDEX IF NOT EXISTS idx_books_deleted_at ON books(deleted_at) WHERE deleted_at IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS users (
+     id BIGSERIAL PRIMARY KEY,
+     username VARCHAR(255) NOT NULL UNIQUE,
+     email VARCHAR(255),
+     password_hash VARCHAR(255) NOT NULL,
+     role VARCHAR(32) NOT NULL DEFAULT 'reader',
+     status VARCHAR(16) NOT NULL DEFAULT 'active'
+         CHECK (status IN ('pending', 'active', 'disabled', 'deleted')),
+     email_verified_at TIMESTAMP WITH TIME ZONE,
+     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- См. комментарий к ALTER TABLE books
+ALTER TABLE users
+     ADD COLUMN IF NOT EXISTS email VARCHAR(255),
+     ADD COLUMN IF NOT EXISTS role VARCHAR(32) NOT NULL DEFAULT 'reader',
+     ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'active'
+         CHECK (status IN ('pending', 'active', 'disabled', 'deleted')),
+     ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMP WITH TIME ZONE;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users(lower(email));
+
+-- Сессии: по одной на логин, все refresh-токены ротации относятся к одной сессии
+CREATE TABLE IF NOT EXISTS sessions (
+     id VARCHAR(64) PRIMARY KEY,
+     user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+     user_agent VARCHAR(512) NOT NULL DEFAULT '',
+     ip_address VARCHAR(64) NOT NULL DEFAULT '',
+     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+     last_used_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+     expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+     revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user ON sessions(user_id);
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+     jti VARCHAR(64) PRIMARY KEY,
+     session_id VARCHAR(64) NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+     expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+     used_at TIMESTAMP WITH TIME ZONE,
+     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_session ON refresh_tokens(session_id);
+
+-- Отозванные access-токены хранятся до истечения их собственного exp
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+     jti VARCHAR(64) PRIMARY KEY,
+     expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+     revoked_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_revoked_tokens_expires ON revoked_tokens(expires_at);
+
+-- API-ключи сервисных клиентов: хранится только префикс и SHA-256 хэш ключа
+CREATE TABLE IF NOT EXISTS api_keys (
+     id BIGSERIAL PRIMARY KEY,
+     user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+     name VARCHAR(255) NOT NULL,
+     prefix VARCHAR(16) NOT NULL UNIQUE,
+     key_hash VARCHAR(64) NOT NULL,
+     scopes TEXT[] NOT NULL DEFAULT '{}',
+     expires_at TIMESTAMP WITH TIME ZONE,
+     last_used_at TIMESTAMP WITH TIME ZONE,
+     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+     revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_user ON api_keys(user_id);
+
+-- Счётчики неудачных входов по имени пользователя ("user:...") и IP ("ip:...")
+CREATE TABLE IF NOT EXISTS login_attempts (
+     key VARCHAR(320) PRIMARY KEY,
+     failures INTEGER NOT NULL DEFAULT 0,
+     window_start TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+     locked_until TIMESTAMP WITH TIME ZONE
+);
+
+-- Секрет TOTP; 2FA включена, когда confirmed_at заполнен
+CREATE TABLE IF NOT EXISTS user_totp (
+     user_id BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+     secret VARCHAR(64) NOT NULL,
+     last_step BIGINT NOT NULL DEFAULT 0,
+     confirmed_at TIMESTAMP WITH TIME ZONE,
+     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Одноразовые коды восстановления 2FA, хранится SHA-256 хэш
+CREATE TABLE IF NOT EXISTS recovery_codes (
+     id BIGSERIAL PRIMARY KEY,
+     user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+     code_hash VARCHAR(64) NOT NULL,
+     used_at TIMESTAMP WITH TIME ZONE,
+     UNIQUE (user_id, code_hash)
+);
+
+-- Одноразовые токены сброса пароля, хранится SHA-256 хэш
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+     token_hash VARCHAR(64) PRIMARY KEY,
+     user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+     expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+     used_at TIMESTAMP WITH TIME ZONE,
+     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_user ON password_reset_tokens(user_id);
+
+-- Одноразовые токены подтверждения email, хранится SHA-256 хэш
+CREATE TABLE IF NOT EXISTS email_verification_tokens (
+     token_hash VARCHAR(64) PRIMARY KEY,
+     user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+     expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+     used_at TIMESTAMP WITH TIME ZONE,
+     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_verification_tokens_user ON email_verification_tokens(user_id);
+
+-- Связь пользователей с учётными записями внешнего IdP (OIDC iss + sub)
+CREATE TABLE IF NOT EXISTS user_identities (
+     issuer VARCHAR(255) NOT NULL,
+     subject VARCHAR(255) NOT NULL,
+     user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+     PRIMARY KEY (issuer, subject)
+);
+
+-- Авторы, редакторы и переводчики книг
+CREATE TABLE IF NOT EXISTS authors (
+     id VARCHAR(36) PRIMARY KEY,
+     name VARCHAR(255) NOT NULL,
+     bio TEXT,
+     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+     updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_authors_name ON authors(name);
+
+-- Связь книг с авторами; position задаёт порядок имён на обложке
+CREATE TABLE IF NOT EXISTS book_authors (
+     book_id VARCHAR(36) NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+     author_id VARCHAR(36) NOT NULL REFERENCES authors(id) ON DELETE RESTRICT,
+     position INTEGER NOT NULL,
+     role VARCHAR(16) NOT NULL DEFAULT 'author' CHECK (role IN ('author', 'editor', 'translator')),
+     PRIMARY KEY (book_id, author_id, role)
+);
+
+CREATE INDEX IF NOT EXISTS idx_book_authors_author ON book_authors(author_id);
+
+-- Иерархический рубрикатор: parent_id ссылается на родительскую рубрику
+CREATE TABLE IF NOT EXISTS categories (
+     id VARCHAR(36) PRIMARY KEY,
+     parent_id VARCHAR(36) REFERENCES categories(id) ON DELETE RESTRICT,
+     name VARCHAR(255) NOT NULL,
+     description TEXT,
+     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+     updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Имена уникальны среди рубрик одного родителя
+CREATE UNIQUE INDEX IF NOT EXISTS idx_categories_parent_name ON categories(COALESCE(parent_id, ''), lower(name));
+
+CREATE TABLE IF NOT EXISTS book_categories (
+     book_id VARCHAR(36) NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+     category_id VARCHAR(36) NOT NULL REFERENCES categories(id) ON DELETE RESTRICT,
+     PRIMARY KEY (book_id, category_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_book_categories_category ON book_categories(category_id);
+
+-- Журнал изменений книг. Записи только добавляются; ссылки на books нет,
+-- чтобы история оставалась после безвозвратного удаления книги.
+CREATE TABLE IF NOT EXISTS book_history (
+     id BIGSERIAL PRIMARY KEY,
+     book_id VARCHAR(36) NOT NULL,
+     version INTEGER NOT NULL,
+     action VARCHAR(16) NOT NULL CHECK (action IN ('create', 'update', 'delete', 'restore', 'purge')),
+     actor VARCHAR(255) NOT NULL DEFAULT '',
+     changed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+     changes JSONB NOT NULL DEFAULT '{}',
+     snapshot JSONB
+);
+
+CREATE INDEX IF NOT EXISTS idx_book_history_book ON book_history(book_id, version);
+
+CREATE OR REPLACE FUNCTION book_history_append_only() RETURNS trigger AS $$
+BEGIN
+     RAISE EXCEPTION 'book_history is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS book_history_append_only ON book_history;
+CREATE TRIGGER book_history_append_only BEFORE UPDATE OR DELETE ON book_history
+     FOR EACH ROW EXECUTE FUNCTION book_history_append_only();
 
 -- Insert some sample data
 INSERT INTO books (id, title, author) VALUES
@@ -32,4 +260,21 @@ INSERT INTO books (id, title, author) VALUES
       ('18', 'Computer Networking: A Top-Down Approach', 'James Kurose, Keith Ross'),
       ('19', 'Python Crash Course', 'Eric Matthes'),
       ('20', 'Fluent Python', 'Luciano Ramalho')
-ON CONFLICT (id) DO NOTHING;
\ No newline at end of file
+ON CONFLICT (id) DO NOTHING;
+
+-- Перенос авторов из books.author (имена через запятую) в authors и book_authors.
+-- Книги, у которых связи уже есть, не трогаются, поэтому скрипт можно выполнять повторно.
+INSERT INTO authors (id, name)
+SELECT gen_random_uuid()::text, names.name
+FROM (SELECT DISTINCT btrim(name) AS name
+      FROM books, unnest(string_to_array(books.author, ',')) AS name) names
+WHERE names.name <> ''
+  AND NOT EXISTS (SELECT 1 FROM authors WHERE authors.name = names.name);
+
+INSERT INTO book_authors (book_id, author_id, position, role)
+SELECT b.id, a.id, split.position, 'author'
+FROM books b
+CROSS JOIN LATERAL unnest(string_to_array(b.author, ',')) WITH ORDINALITY AS split(name, position)
+JOIN LATERAL (SELECT id FROM authors WHERE name = btrim(split.name) ORDER BY created_at LIMIT 1) a ON true
+WHERE NOT EXISTS (SELECT 1 FROM book_authors WHERE book_id = b.id)
+ON CONFLICT DO NOTHING;
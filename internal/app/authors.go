@@ -0,0 +1,203 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+	"tspo_server/internal/auth"
+	"tspo_server/internal/db"
+	"tspo_server/internal/errors"
+	"tspo_server/internal/query"
+	"tspo_server/model"
+)
+
+// AuthorHandler - справочник авторов. Изменения закрыты теми же разрешениями, что и книги.
+type AuthorHandler struct {
+	authors *db.AuthorRepository
+	books   *db.BookRepository
+	logger  *slog.Logger
+}
+
+// AuthorRequest - тело POST и PUT /authors; id автора всегда генерирует сервер
+type AuthorRequest struct {
+	Name string `json:"name"`
+	Bio  string `json:"bio,omitempty"`
+}
+
+var authorListOptions = query.Options{
+	DefaultSort: "name",
+	Sortable:    []string{"id", "name", "created_at", "updated_at"},
+	Filters:     []string{"name"},
+}
+
+func NewAuthorHandler(authors *db.AuthorRepository, books *db.BookRepository, logger *slog.Logger) *AuthorHandler {
+	return &AuthorHandler{
+		authors: authors,
+		books:   books,
+		logger:  logger,
+	}
+}
+
+func (h *AuthorHandler) ListAuthors(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	params := query.NewParamsWithOptions(r, authorListOptions)
+	authors, total, err := h.authors.ListAuthors(ctx, params)
+	if err != nil {
+		h.logger.Error("failed to list authors", "error", err)
+		writeError(w, err, "Resource already exists")
+		return
+	}
+	if authors == nil {
+		authors = []model.Author{}
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Data:       authors,
+		Pagination: newPagination(params, total),
+	})
+}
+
+func (h *AuthorHandler) GetAuthor(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	author, ok := h.loadAuthor(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Response{Data: author})
+}
+
+// GetAuthorBooks возвращает книги автора в любой роли; параметры списка те же, что у GET /books
+func (h *AuthorHandler) GetAuthorBooks(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	author, ok := h.loadAuthor(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	params := query.NewParams(r)
+	params.Filter["author_id"] = author.ID
+	books, total, err := h.books.GetBooks(ctx, params)
+	if err != nil {
+		h.logger.Error("failed to get author books", "error", err, "id", author.ID)
+		writeError(w, err, "Resource already exists")
+		return
+	}
+	if books == nil {
+		books = []model.Book{}
+	}
+	for i := range books {
+		books[i].ETag = bookETag(&books[i])
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Data:       books,
+		Pagination: newPagination(params, total),
+	})
+}
+
+func (h *AuthorHandler) CreateAuthor(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	req, ok := decodeAuthorRequest(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		h.logger.Error("failed to generate author id", "error", err)
+		writeAPIError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	author := &model.Author{ID: id.String(), Name: req.Name, Bio: req.Bio}
+	if err = h.authors.CreateAuthor(ctx, author); err != nil {
+		h.logger.Error("failed to create author", "error", err)
+		writeError(w, err, "Author already exists")
+		return
+	}
+
+	w.Header().Set("Location", "/authors/"+author.ID)
+	writeJSON(w, http.StatusCreated, Response{Data: author})
+}
+
+// UpdateAuthor меняет имя и биографию; строка author у книг автора пересчитывается
+func (h *AuthorHandler) UpdateAuthor(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	req, ok := decodeAuthorRequest(w, r)
+	if !ok {
+		return
+	}
+
+	author := &model.Author{ID: r.PathValue("id"), Name: req.Name, Bio: req.Bio}
+	err := h.authors.UpdateAuthor(ctx, author, auth.UsernameFromContext(r.Context()))
+	if err != nil {
+		h.logger.Error("failed to update author", "error", err, "id", author.ID)
+		writeError(w, err, "Author already exists")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Response{Data: author})
+}
+
+// DeleteAuthor удаляет автора, только если он не указан ни в одной книге
+func (h *AuthorHandler) DeleteAuthor(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	id := r.PathValue("id")
+	if err := h.authors.DeleteAuthor(ctx, id); err != nil {
+		h.logger.Error("failed to delete author", "error", err, "id", id)
+		writeError(w, err, "Author is referenced by books")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AuthorHandler) loadAuthor(ctx context.Context, w http.ResponseWriter, r *http.Request) (*model.Author, bool) {
+	id := r.PathValue("id")
+	author, err := h.authors.GetAuthor(ctx, id)
+	if err != nil {
+		h.logger.Error("failed to get author", "error", err, "id", id)
+		writeError(w, err, "Resource already exists")
+		return nil, false
+	}
+	return author, true
+}
+
+func decodeAuthorRequest(w http.ResponseWriter, r *http.Request) (*AuthorRequest, bool) {
+	var req AuthorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "Invalid request body")
+		return nil, false
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	req.Bio = strings.TrimSpace(req.Bio)
+
+	var violations []errors.FieldError
+	violations = appendRequired(violations, "name", req.Name, maxAuthorNameLength)
+	violations = appendMaxLength(violations, "bio", req.Bio, maxDescriptionLength)
+	if len(violations) > 0 {
+		writeJSON(w, http.StatusUnprocessableEntity, Response{
+			Error: errors.NewValidationAPIError(http.StatusUnprocessableEntity, "Author data is invalid", violations),
+		})
+		return nil, false
+	}
+	return &req, true
+}
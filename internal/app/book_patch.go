@@ -0,0 +1,201 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/evanphx/json-patch/v5"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"time"
+	"tspo_server/internal/auth"
+	"tspo_server/internal/db"
+	"tspo_server/internal/errors"
+	"tspo_server/model"
+)
+
+const (
+	mediaTypeMergePatch = "application/merge-patch+json"
+	mediaTypeJSONPatch  = "application/json-patch+json"
+)
+
+// Поля, которые PATCH не может изменить: их заполняет сервер
+var readOnlyBookFields = []string{"id", "version", "etag", "created_by", "created_at", "updated_at", "deleted_at"}
+
+// PatchBook применяет к книге JSON Merge Patch (RFC 7396) или JSON Patch (RFC 6902)
+// и сохраняет только изменившиеся поля. Патч применяется к тому же JSON-представлению,
+// которое возвращает GET /books/{id}, а результат проходит ту же валидацию, что и PUT.
+func (h *Handler) PatchBook(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	id := r.PathValue("id")
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != mediaTypeMergePatch && mediaType != mediaTypeJSONPatch {
+		writeAPIError(w, http.StatusUnsupportedMediaType,
+			"Content-Type must be "+mediaTypeMergePatch+" or "+mediaTypeJSONPatch)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		h.writeError(w, errors.ErrInvalidInput)
+		return
+	}
+
+	version, ok := h.expectedVersion(ctx, w, r, id, h.repo.GetBook)
+	if !ok {
+		return
+	}
+
+	current, err := h.repo.GetBook(ctx, id)
+	if err != nil {
+		h.logger.Error("failed to get book", "error", err, "id", id)
+		h.writeError(w, err)
+		return
+	}
+	if version != 0 && version != current.Version {
+		h.writeError(w, errors.ErrPreconditionFailed)
+		return
+	}
+	// патч применяется к прочитанной версии, поэтому записать его можно только поверх неё
+	version = current.Version
+
+	original, err := json.Marshal(current)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	var patched []byte
+	if mediaType == mediaTypeMergePatch {
+		patched, err = jsonpatch.MergePatch(original, body)
+	} else {
+		var patch jsonpatch.Patch
+		if patch, err = jsonpatch.DecodePatch(body); err == nil {
+			patched, err = patch.Apply(original)
+		}
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, "Patch cannot be applied: "+err.Error())
+		return
+	}
+
+	fields, err := changedFields(original, patched)
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, "Patch cannot be applied: "+err.Error())
+		return
+	}
+	if len(fields) == 0 {
+		h.writeBook(w, http.StatusOK, current)
+		return
+	}
+
+	var violations []errors.FieldError
+	for _, field := range fields {
+		switch {
+		case contains(readOnlyBookFields, field):
+			violations = append(violations, errors.FieldError{
+				Field: field, Code: "read_only", Message: field + " cannot be changed",
+			})
+		case !db.IsBookField(field):
+			violations = append(violations, errors.FieldError{
+				Field: field, Code: "unknown_field", Message: field + " is not a book field",
+			})
+		}
+	}
+	if len(violations) > 0 {
+		h.writeValidationError(w, violations)
+		return
+	}
+
+	var book model.Book
+	if err = json.Unmarshal(patched, &book); err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, "Patched document is not a valid book: "+err.Error())
+		return
+	}
+
+	if contains(fields, "author") && !contains(fields, "authors") {
+		// авторы заново разбираются из строки author, поэтому проверять нужно её
+		book.Authors = nil
+	}
+
+	normalizeBook(&book)
+	if violations = validateBook(&book); len(violations) > 0 {
+		h.writeValidationError(w, violations)
+		return
+	}
+
+	if err = h.repo.PatchBook(ctx, &book, fields, version, auth.UsernameFromContext(r.Context())); err != nil {
+		h.logger.Error("failed to patch book", "error", err, "id", id)
+		h.writeError(w, err)
+		return
+	}
+
+	h.writeBook(w, http.StatusOK, &book)
+}
+
+// Поля, которые всегда есть в представлении книги и поэтому обязательны в теле PUT.
+// Необязательные поля в ответе опускаются, поэтому их отсутствие в PUT означает очистку.
+var putRequiredBookFields = []string{"title", "tags", "categories"}
+
+// missingPutFields проверяет, что тело PUT содержит полное представление книги,
+// а не его часть: частичные изменения делаются через PATCH
+func missingPutFields(body []byte) []errors.FieldError {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil // ошибку формата вернёт разбор тела
+	}
+
+	var violations []errors.FieldError
+	missing := func(field string) {
+		violations = append(violations, errors.FieldError{
+			Field: field, Code: "missing", Message: field + " is required in PUT; use PATCH for partial updates",
+		})
+	}
+	for _, field := range putRequiredBookFields {
+		if _, ok := doc[field]; !ok {
+			missing(field)
+		}
+	}
+	_, hasAuthor := doc["author"]
+	_, hasAuthors := doc["authors"]
+	if !hasAuthor && !hasAuthors {
+		missing("authors")
+	}
+	return violations
+}
+
+// changedFields возвращает имена полей верхнего уровня, значения которых различаются
+func changedFields(original, patched []byte) ([]string, error) {
+	var before, after map[string]interface{}
+	if err := json.Unmarshal(original, &before); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(patched, &after); err != nil {
+		return nil, err
+	}
+
+	var fields []string
+	for key, value := range after {
+		if !reflect.DeepEqual(before[key], value) {
+			fields = append(fields, key)
+		}
+	}
+	for key := range before {
+		if _, ok := after[key]; !ok {
+			fields = append(fields, key)
+		}
+	}
+	return fields, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
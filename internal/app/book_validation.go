@@ -0,0 +1,280 @@
+package app
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"regexp"
+	"strings"
+	"time"
+	"tspo_server/internal/errors"
+	"tspo_server/model"
+	"unicode/utf8"
+)
+
+// Ограничения длины совпадают с размерами столбцов в init.sql
+const (
+	maxTitleLength        = 255
+	maxAuthorLength       = 255
+	maxPublisherLength    = 255
+	maxLanguageLength     = 35
+	maxEditionLength      = 64
+	maxDescriptionLength  = 10000
+	maxAuthorNameLength   = 255
+	maxBookAuthors        = 50
+	maxBookCategories     = 20
+	maxCategoryNameLength = 255
+	maxTagLength          = 64
+	maxTags               = 32
+	maxPageCount          = 100000
+	minPublicationYear    = 1450 // год издания, а не написания: раньше книгопечатания издания не бывает
+	publicationYearMargin = 1    // допускаются анонсированные книги следующего года
+)
+
+// Код языка в духе BCP 47: "en", "ru", "pt-BR", "zh-Hant"
+var languagePattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// normalizeBook убирает лишние пробелы и разделители в ISBN перед проверкой и сохранением
+func normalizeBook(book *model.Book) {
+	book.ID = strings.TrimSpace(book.ID)
+	book.Title = strings.TrimSpace(book.Title)
+	book.Author = strings.TrimSpace(book.Author)
+	book.ISBN10 = normalizeISBN(book.ISBN10)
+	book.ISBN13 = normalizeISBN(book.ISBN13)
+	book.Publisher = strings.TrimSpace(book.Publisher)
+	book.Language = strings.TrimSpace(book.Language)
+	book.Description = strings.TrimSpace(book.Description)
+	book.Edition = strings.TrimSpace(book.Edition)
+	for i, tag := range book.Tags {
+		book.Tags[i] = strings.TrimSpace(tag)
+	}
+	for i := range book.Categories {
+		book.Categories[i].ID = strings.TrimSpace(book.Categories[i].ID)
+	}
+	for i := range book.Authors {
+		author := &book.Authors[i]
+		author.ID = strings.TrimSpace(author.ID)
+		author.Name = strings.TrimSpace(author.Name)
+		author.Role = strings.ToLower(strings.TrimSpace(author.Role))
+		if author.Role == "" {
+			author.Role = model.AuthorRoleAuthor
+		}
+	}
+}
+
+// validateBook проверяет книгу перед записью и возвращает список нарушений по полям.
+// Пустой список означает, что книгу можно сохранять.
+func validateBook(book *model.Book) []errors.FieldError {
+	var violations []errors.FieldError
+
+	violations = appendRequired(violations, "title", book.Title, maxTitleLength)
+	if len(book.Authors) == 0 {
+		violations = appendRequired(violations, "author", book.Author, maxAuthorLength)
+	}
+	violations = append(violations, validateBookAuthors(book.Authors)...)
+	violations = append(violations, validateBookCategories(book.Categories)...)
+	violations = appendMaxLength(violations, "publisher", book.Publisher, maxPublisherLength)
+	violations = appendMaxLength(violations, "edition", book.Edition, maxEditionLength)
+	violations = appendMaxLength(violations, "description", book.Description, maxDescriptionLength)
+
+	if book.ISBN10 != "" && !validISBN10(book.ISBN10) {
+		violations = append(violations, errors.FieldError{
+			Field: "isbn_10", Code: "invalid_isbn", Message: "isbn_10 must be 10 characters with a valid check digit",
+		})
+	}
+	if book.ISBN13 != "" && !validISBN13(book.ISBN13) {
+		violations = append(violations, errors.FieldError{
+			Field: "isbn_13", Code: "invalid_isbn", Message: "isbn_13 must be 13 digits starting with 978 or 979 with a valid check digit",
+		})
+	}
+
+	maxYear := time.Now().Year() + publicationYearMargin
+	if book.PublicationYear != 0 && (book.PublicationYear < minPublicationYear || book.PublicationYear > maxYear) {
+		violations = append(violations, errors.FieldError{
+			Field: "publication_year", Code: "out_of_range",
+			Message: fmt.Sprintf("publication_year must be between %d and %d", minPublicationYear, maxYear),
+		})
+	}
+	if book.PageCount < 0 || book.PageCount > maxPageCount {
+		violations = append(violations, errors.FieldError{
+			Field: "page_count", Code: "out_of_range",
+			Message: fmt.Sprintf("page_count must be between 1 and %d", maxPageCount),
+		})
+	}
+
+	if book.Language != "" {
+		if utf8.RuneCountInString(book.Language) > maxLanguageLength || !languagePattern.MatchString(book.Language) {
+			violations = append(violations, errors.FieldError{
+				Field: "language", Code: "invalid", Message: "language must be a language tag such as en or pt-BR",
+			})
+		}
+	}
+
+	if len(book.Tags) > maxTags {
+		violations = append(violations, errors.FieldError{
+			Field: "tags", Code: "too_many", Message: fmt.Sprintf("at most %d tags are allowed", maxTags),
+		})
+	}
+	for i, tag := range book.Tags {
+		violations = appendRequired(violations, fmt.Sprintf("tags[%d]", i), tag, maxTagLength)
+	}
+
+	return violations
+}
+
+// validateBookAuthors проверяет, что каждый участник задан id или именем, роль известна,
+// а один и тот же автор не указан дважды в одной роли
+func validateBookAuthors(authors []model.BookAuthor) []errors.FieldError {
+	var violations []errors.FieldError
+	if len(authors) > maxBookAuthors {
+		violations = append(violations, errors.FieldError{
+			Field: "authors", Code: "too_many", Message: fmt.Sprintf("at most %d authors are allowed", maxBookAuthors),
+		})
+	}
+
+	seen := make(map[string]bool, len(authors))
+	for i, author := range authors {
+		field := fmt.Sprintf("authors[%d]", i)
+		switch {
+		case author.ID != "":
+			if _, err := uuid.Parse(author.ID); err != nil {
+				violations = append(violations, errors.FieldError{
+					Field: field + ".id", Code: "invalid", Message: "author id must be a UUID",
+				})
+			}
+		case author.Name == "":
+			violations = append(violations, errors.FieldError{
+				Field: field, Code: "required", Message: "author id or name is required",
+			})
+		default:
+			violations = appendMaxLength(violations, field+".name", author.Name, maxAuthorNameLength)
+		}
+
+		switch author.Role {
+		case model.AuthorRoleAuthor, model.AuthorRoleEditor, model.AuthorRoleTranslator:
+		default:
+			violations = append(violations, errors.FieldError{
+				Field: field + ".role", Code: "invalid", Message: "role must be author, editor or translator",
+			})
+		}
+
+		key := author.ID + "|" + author.Name + "|" + author.Role
+		if author.ID != "" {
+			key = author.ID + "||" + author.Role
+		}
+		if seen[key] {
+			violations = append(violations, errors.FieldError{
+				Field: field, Code: "duplicate", Message: "author is listed twice with the same role",
+			})
+		}
+		seen[key] = true
+	}
+	return violations
+}
+
+func validateBookCategories(categories []model.BookCategory) []errors.FieldError {
+	var violations []errors.FieldError
+	if len(categories) > maxBookCategories {
+		violations = append(violations, errors.FieldError{
+			Field: "categories", Code: "too_many", Message: fmt.Sprintf("at most %d categories are allowed", maxBookCategories),
+		})
+	}
+
+	seen := make(map[string]bool, len(categories))
+	for i, category := range categories {
+		field := fmt.Sprintf("categories[%d].id", i)
+		if _, err := uuid.Parse(category.ID); err != nil {
+			violations = append(violations, errors.FieldError{
+				Field: field, Code: "invalid", Message: "category id must be a UUID",
+			})
+			continue
+		}
+		if seen[category.ID] {
+			violations = append(violations, errors.FieldError{
+				Field: field, Code: "duplicate", Message: "category is listed twice",
+			})
+		}
+		seen[category.ID] = true
+	}
+	return violations
+}
+
+// validateBookID принимает только UUID и приводит его к каноническому виду.
+// Пустой id допустим: сервер сгенерирует его сам.
+func validateBookID(book *model.Book) []errors.FieldError {
+	if book.ID == "" {
+		return nil
+	}
+	id, err := uuid.Parse(book.ID)
+	if err != nil {
+		return []errors.FieldError{{Field: "id", Code: "invalid", Message: "id must be a UUID"}}
+	}
+	book.ID = id.String()
+	return nil
+}
+
+func appendRequired(violations []errors.FieldError, field, value string, maxLength int) []errors.FieldError {
+	if value == "" {
+		return append(violations, errors.FieldError{
+			Field: field, Code: "required", Message: field + " is required",
+		})
+	}
+	return appendMaxLength(violations, field, value, maxLength)
+}
+
+// appendMaxLength считает длину в символах, как VARCHAR(n) в PostgreSQL
+func appendMaxLength(violations []errors.FieldError, field, value string, maxLength int) []errors.FieldError {
+	if utf8.RuneCountInString(value) > maxLength {
+		return append(violations, errors.FieldError{
+			Field: field, Code: "too_long",
+			Message: fmt.Sprintf("%s must be at most %d characters long", field, maxLength),
+		})
+	}
+	return violations
+}
+
+func normalizeISBN(isbn string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(isbn))
+}
+
+// validISBN10 проверяет контрольную сумму: sum(i * d_i) для i = 10..1 делится на 11,
+// последний символ может быть X (10)
+func validISBN10(isbn string) bool {
+	if len(isbn) != 10 {
+		return false
+	}
+	sum := 0
+	for i := 0; i < 10; i++ {
+		c := isbn[i]
+		var digit int
+		switch {
+		case c >= '0' && c <= '9':
+			digit = int(c - '0')
+		case c == 'X' && i == 9:
+			digit = 10
+		default:
+			return false
+		}
+		sum += (10 - i) * digit
+	}
+	return sum%11 == 0
+}
+
+// validISBN13 проверяет префикс EAN (978/979) и контрольную сумму с весами 1 и 3
+func validISBN13(isbn string) bool {
+	if len(isbn) != 13 || (!strings.HasPrefix(isbn, "978") && !strings.HasPrefix(isbn, "979")) {
+		return false
+	}
+	sum := 0
+	for i := 0; i < 13; i++ {
+		c := isbn[i]
+		if c < '0' || c > '9' {
+			return false
+		}
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += weight * int(c-'0')
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,186 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	goerrors "errors"
+	"github.com/google/uuid"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+	"tspo_server/internal/auth"
+	"tspo_server/internal/db"
+	"tspo_server/internal/errors"
+	"tspo_server/internal/query"
+	"tspo_server/model"
+)
+
+// CategoryHandler - рубрикатор книг. Изменения закрыты теми же разрешениями, что и книги.
+type CategoryHandler struct {
+	categories *db.CategoryRepository
+	logger     *slog.Logger
+}
+
+// CategoryRequest - тело POST и PUT /categories; пустой parent_id делает рубрику корневой
+type CategoryRequest struct {
+	ParentID    string `json:"parent_id,omitempty"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+var categoryListOptions = query.Options{
+	DefaultSort: "name",
+	Sortable:    []string{"id", "name", "created_at", "updated_at"},
+	Filters:     []string{"name", "parent_id"},
+}
+
+func NewCategoryHandler(categories *db.CategoryRepository, logger *slog.Logger) *CategoryHandler {
+	return &CategoryHandler{
+		categories: categories,
+		logger:     logger,
+	}
+}
+
+func (h *CategoryHandler) ListCategories(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	params := query.NewParamsWithOptions(r, categoryListOptions)
+	categories, total, err := h.categories.ListCategories(ctx, params)
+	if err != nil {
+		h.logger.Error("failed to list categories", "error", err)
+		writeError(w, err, "Resource already exists")
+		return
+	}
+	if categories == nil {
+		categories = []model.Category{}
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Data:       categories,
+		Pagination: newPagination(params, total),
+	})
+}
+
+func (h *CategoryHandler) GetCategory(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	id := r.PathValue("id")
+	category, err := h.categories.GetCategory(ctx, id)
+	if err != nil {
+		h.logger.Error("failed to get category", "error", err, "id", id)
+		writeError(w, err, "Resource already exists")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Response{Data: category})
+}
+
+func (h *CategoryHandler) CreateCategory(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	req, ok := decodeCategoryRequest(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		h.logger.Error("failed to generate category id", "error", err)
+		writeAPIError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	category := &model.Category{ID: id.String(), ParentID: req.ParentID, Name: req.Name, Description: req.Description}
+	if !h.writeCategoryError(w, h.categories.CreateCategory(ctx, category), category.ID) {
+		return
+	}
+
+	w.Header().Set("Location", "/categories/"+category.ID)
+	writeJSON(w, http.StatusCreated, Response{Data: category})
+}
+
+// UpdateCategory переименовывает рубрику или переносит её к другому родителю
+func (h *CategoryHandler) UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	req, ok := decodeCategoryRequest(w, r)
+	if !ok {
+		return
+	}
+
+	category := &model.Category{ID: r.PathValue("id"), ParentID: req.ParentID, Name: req.Name, Description: req.Description}
+	if !h.writeCategoryError(w, h.categories.UpdateCategory(ctx, category, auth.UsernameFromContext(r.Context())), category.ID) {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Response{Data: category})
+}
+
+// DeleteCategory удаляет рубрику, только если у неё нет подрубрик и книг
+func (h *CategoryHandler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	id := r.PathValue("id")
+	if err := h.categories.DeleteCategory(ctx, id); err != nil {
+		h.logger.Error("failed to delete category", "error", err, "id", id)
+		writeError(w, err, "Category has subcategories or books")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeCategoryError пишет ответ для ошибки записи рубрики и возвращает false, если ошибка была
+func (h *CategoryHandler) writeCategoryError(w http.ResponseWriter, err error, id string) bool {
+	switch {
+	case err == nil:
+		return true
+	case goerrors.Is(err, errors.ErrInvalidInput):
+		writeJSON(w, http.StatusUnprocessableEntity, Response{
+			Error: errors.NewValidationAPIError(http.StatusUnprocessableEntity, "Category data is invalid", []errors.FieldError{{
+				Field: "parent_id", Code: "invalid",
+				Message: "parent_id must reference an existing category other than this one or its descendants",
+			}}),
+		})
+	default:
+		h.logger.Error("failed to save category", "error", err, "id", id)
+		writeError(w, err, "Category with this name already exists under the parent")
+	}
+	return false
+}
+
+func decodeCategoryRequest(w http.ResponseWriter, r *http.Request) (*CategoryRequest, bool) {
+	var req CategoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "Invalid request body")
+		return nil, false
+	}
+
+	req.ParentID = strings.TrimSpace(req.ParentID)
+	req.Name = strings.TrimSpace(req.Name)
+	req.Description = strings.TrimSpace(req.Description)
+
+	var violations []errors.FieldError
+	violations = appendRequired(violations, "name", req.Name, maxCategoryNameLength)
+	violations = appendMaxLength(violations, "description", req.Description, maxDescriptionLength)
+	if req.ParentID != "" {
+		if _, err := uuid.Parse(req.ParentID); err != nil {
+			violations = append(violations, errors.FieldError{
+				Field: "parent_id", Code: "invalid", Message: "parent_id must be a UUID",
+			})
+		}
+	}
+	if len(violations) > 0 {
+		writeJSON(w, http.StatusUnprocessableEntity, Response{
+			Error: errors.NewValidationAPIError(http.StatusUnprocessableEntity, "Category data is invalid", violations),
+		})
+		return nil, false
+	}
+	return &req, true
+}
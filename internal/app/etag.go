@@ -0,0 +1,112 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"tspo_server/internal/errors"
+	"tspo_server/model"
+)
+
+// bookETag - сильный ETag книги, построенный по её версии
+func bookETag(book *model.Book) string {
+	return strconv.Quote(strconv.Itoa(book.Version))
+}
+
+// listETag - слабый ETag страницы списка: меняется, если меняется состав страницы,
+// версия любой книги на ней или общее число записей
+func listETag(books []model.Book, total int) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%d", total)
+	for _, book := range books {
+		fmt.Fprintf(hash, ";%s:%d", book.ID, book.Version)
+	}
+	return `W/"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+}
+
+// writeBook отдаёт книгу вместе с заголовком ETag
+func (h *Handler) writeBook(w http.ResponseWriter, status int, book *model.Book) {
+	book.ETag = bookETag(book)
+	w.Header().Set("ETag", book.ETag)
+	h.writeJSON(w, status, Response{Data: book})
+}
+
+// notModified сообщает, совпадает ли If-None-Match с текущим ETag. Для If-None-Match
+// используется слабое сравнение (RFC 9110, 13.1.2), поэтому префикс W/ не учитывается.
+func notModified(r *http.Request, etag string) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, tag := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// parseIfMatch возвращает версии из If-Match. Для If-Match используется сильное сравнение,
+// поэтому слабые и чужие теги пропускаются и не совпадают ни с одной версией.
+func parseIfMatch(header string) (versions []int, any bool) {
+	if strings.TrimSpace(header) == "*" {
+		return nil, true
+	}
+	for _, tag := range strings.Split(header, ",") {
+		value, err := strconv.Unquote(strings.TrimSpace(tag))
+		if err != nil {
+			continue
+		}
+		if version, err := strconv.Atoi(value); err == nil && version > 0 {
+			versions = append(versions, version)
+		}
+	}
+	return versions, false
+}
+
+// expectedVersion возвращает версию книги из If-Match для условной записи (0 - без проверки).
+// Без заголовка пишет 428, если он обязателен; при нескольких тегах сверяет их с текущей
+// версией книги, которую читает lookup. ok = false, если ответ уже записан.
+func (h *Handler) expectedVersion(ctx context.Context, w http.ResponseWriter, r *http.Request, id string,
+	lookup func(context.Context, string) (*model.Book, error)) (int, bool) {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		if h.requireIfMatch {
+			writeAPIError(w, http.StatusPreconditionRequired, "If-Match header is required")
+			return 0, false
+		}
+		return 0, true
+	}
+
+	versions, any := parseIfMatch(header)
+	switch {
+	case any:
+		return 0, true
+	case len(versions) == 0:
+		h.writeError(w, errors.ErrPreconditionFailed)
+		return 0, false
+	case len(versions) == 1:
+		return versions[0], true
+	}
+
+	book, err := lookup(ctx, id)
+	if err != nil {
+		h.writeError(w, err)
+		return 0, false
+	}
+	for _, version := range versions {
+		if version == book.Version {
+			return version, true
+		}
+	}
+	h.writeError(w, errors.ErrPreconditionFailed)
+	return 0, false
+}
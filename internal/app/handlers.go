@@ -3,10 +3,14 @@ package app
 import (
 	"context"
 	"encoding/json"
+	goerrors "errors"
+	"github.com/google/uuid"
+	"io"
 	"log/slog"
 	"net/http"
 	"strings"
 	"time"
+	"tspo_server/internal/auth"
 	"tspo_server/internal/db"
 	"tspo_server/internal/errors"
 	"tspo_server/internal/query"
@@ -14,8 +18,9 @@ import (
 )
 
 type Handler struct {
-	repo   *db.BookRepository
-	logger *slog.Logger
+	repo           *db.BookRepository
+	logger         *slog.Logger
+	requireIfMatch bool // PUT, PATCH и DELETE без If-Match отклоняются с 428
 }
 
 type Response struct {
@@ -31,31 +36,56 @@ type Pagination struct {
 	TotalRecords int `json:"total_records"`
 }
 
-func NewHandler(repo *db.BookRepository, logger *slog.Logger) *Handler {
+func newPagination(params *query.Params, total int) *Pagination {
+	return &Pagination{
+		CurrentPage:  params.Page,
+		PageSize:     params.PageSize,
+		TotalPages:   (total + params.PageSize - 1) / params.PageSize,
+		TotalRecords: total,
+	}
+}
+
+func NewHandler(repo *db.BookRepository, logger *slog.Logger, requireIfMatch bool) *Handler {
 	return &Handler{
-		repo:   repo,
-		logger: logger,
+		repo:           repo,
+		logger:         logger,
+		requireIfMatch: requireIfMatch,
 	}
 }
 
 func (h *Handler) writeJSON(w http.ResponseWriter, status int, data interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(data)
+	writeJSON(w, status, data)
 }
 
 func (h *Handler) writeError(w http.ResponseWriter, err error) {
+	var fieldErr *errors.FieldValidationError
+	if goerrors.As(err, &fieldErr) {
+		h.writeValidationError(w, []errors.FieldError{fieldErr.Field})
+		return
+	}
+	writeError(w, err, "Resource already exists")
+}
+
+// writeError отдаёт статус, соответствующий ошибке из internal/errors, одинаково для всех
+// ресурсов. conflict - текст ответа 409, причина конфликта у каждого ресурса своя.
+func writeError(w http.ResponseWriter, err error, conflict string) {
 	var status int
 	var message string
 
 	switch {
-	case err == errors.ErrNotFound:
+	case goerrors.Is(err, errors.ErrNotFound):
 		status = http.StatusNotFound
 		message = "Resource not found"
-	case err == errors.ErrInvalidInput:
+	case goerrors.Is(err, errors.ErrInvalidInput):
 		status = http.StatusBadRequest
 		message = "Invalid input"
-	case err == errors.ErrTimeout:
+	case goerrors.Is(err, errors.ErrConflict):
+		status = http.StatusConflict
+		message = conflict
+	case goerrors.Is(err, errors.ErrPreconditionFailed):
+		status = http.StatusPreconditionFailed
+		message = "Resource has been modified"
+	case goerrors.Is(err, errors.ErrTimeout):
 		status = http.StatusGatewayTimeout
 		message = "Operation timed out"
 	default:
@@ -63,11 +93,17 @@ func (h *Handler) writeError(w http.ResponseWriter, err error) {
 		message = "Internal server error"
 	}
 
-	h.writeJSON(w, status, Response{
+	writeJSON(w, status, Response{
 		Error: errors.NewAPIError(status, message),
 	})
 }
 
+func (h *Handler) writeValidationError(w http.ResponseWriter, violations []errors.FieldError) {
+	h.writeJSON(w, http.StatusUnprocessableEntity, Response{
+		Error: errors.NewValidationAPIError(http.StatusUnprocessableEntity, "Book data is invalid", violations),
+	})
+}
+
 func (h *Handler) GetBooks(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
@@ -80,15 +116,19 @@ func (h *Handler) GetBooks(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	totalPages := (total + params.PageSize - 1) / params.PageSize
+	for i := range books {
+		books[i].ETag = bookETag(&books[i])
+	}
+	etag := listETag(books, total)
+	w.Header().Set("ETag", etag)
+	if notModified(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
 	h.writeJSON(w, http.StatusOK, Response{
-		Data: books,
-		Pagination: &Pagination{
-			CurrentPage:  params.Page,
-			PageSize:     params.PageSize,
-			TotalPages:   totalPages,
-			TotalRecords: total,
-		},
+		Data:       books,
+		Pagination: newPagination(params, total),
 	})
 }
 
@@ -104,7 +144,13 @@ func (h *Handler) GetBook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	h.writeJSON(w, http.StatusOK, Response{Data: book})
+	if etag := bookETag(book); notModified(r, etag) {
+		w.Header().Set("ETag", etag)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	h.writeBook(w, http.StatusOK, book)
 }
 
 func (h *Handler) CreateBook(w http.ResponseWriter, r *http.Request) {
@@ -118,13 +164,32 @@ func (h *Handler) CreateBook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	normalizeBook(&book)
+	violations := append(validateBookID(&book), validateBook(&book)...)
+	if len(violations) > 0 {
+		h.writeValidationError(w, violations)
+		return
+	}
+
+	if book.ID == "" {
+		id, err := uuid.NewV7()
+		if err != nil {
+			h.logger.Error("failed to generate book id", "error", err)
+			h.writeError(w, err)
+			return
+		}
+		book.ID = id.String()
+	}
+
+	book.CreatedBy = auth.UsernameFromContext(r.Context())
 	if err := h.repo.CreateBook(ctx, &book); err != nil {
-		h.logger.Error("failed to create book", "error", err)
+		h.logger.Error("failed to create book", "error", err, "id", book.ID)
 		h.writeError(w, err)
 		return
 	}
 
-	h.writeJSON(w, http.StatusCreated, Response{Data: book})
+	w.Header().Set("Location", "/books/"+book.ID)
+	h.writeBook(w, http.StatusCreated, &book)
 }
 
 func (h *Handler) UpdateBook(w http.ResponseWriter, r *http.Request) {
@@ -132,33 +197,83 @@ func (h *Handler) UpdateBook(w http.ResponseWriter, r *http.Request) {
 	defer cancel()
 
 	id := strings.TrimPrefix(r.URL.Path, "/books/")
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		h.writeError(w, errors.ErrInvalidInput)
+		return
+	}
+
 	var book model.Book
-	if err := json.NewDecoder(r.Body).Decode(&book); err != nil {
+	if err = json.Unmarshal(body, &book); err != nil {
 		h.logger.Error("failed to decode request", "error", err)
 		h.writeError(w, errors.ErrInvalidInput)
 		return
 	}
+	if violations := missingPutFields(body); len(violations) > 0 {
+		h.writeValidationError(w, violations)
+		return
+	}
 
 	book.ID = id
-	if err := h.repo.UpdateBook(ctx, &book); err != nil {
+	normalizeBook(&book)
+	if violations := validateBook(&book); len(violations) > 0 {
+		h.writeValidationError(w, violations)
+		return
+	}
+
+	version, ok := h.expectedVersion(ctx, w, r, id, h.repo.GetBook)
+	if !ok {
+		return
+	}
+
+	if err := h.repo.UpdateBook(ctx, &book, version, auth.UsernameFromContext(r.Context())); err != nil {
 		h.logger.Error("failed to update book", "error", err, "id", id)
 		h.writeError(w, err)
 		return
 	}
 
-	h.writeJSON(w, http.StatusOK, Response{Data: book})
+	h.writeBook(w, http.StatusOK, &book)
 }
 
+// DeleteBook перемещает книгу в корзину. С ?hard=true книга удаляется безвозвратно,
+// это доступно только ролям с разрешением books:purge.
 func (h *Handler) DeleteBook(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
 	id := strings.TrimPrefix(r.URL.Path, "/books/")
-	if err := h.repo.DeleteBook(ctx, id); err != nil {
-		h.logger.Error("failed to delete book", "error", err, "id", id)
+	hard := r.URL.Query().Get("hard") == "true"
+	if hard {
+		claims, ok := auth.ClaimsFromContext(r.Context())
+		if !ok || !claims.Can(auth.PermBooksPurge) {
+			writeAPIError(w, http.StatusForbidden, "Insufficient permissions")
+			return
+		}
+	}
+
+	// книгу из корзины можно удалить безвозвратно, поэтому версия ищется и среди удалённых
+	lookup := h.repo.GetBook
+	if hard {
+		lookup = h.repo.GetBookWithDeleted
+	}
+	version, ok := h.expectedVersion(ctx, w, r, id, lookup)
+	if !ok {
+		return
+	}
+
+	actor := auth.UsernameFromContext(r.Context())
+	var err error
+	if hard {
+		err = h.repo.PurgeBook(ctx, id, version, actor)
+	} else {
+		err = h.repo.DeleteBook(ctx, id, version, actor)
+	}
+	if err != nil {
+		h.logger.Error("failed to delete book", "error", err, "id", id, "hard", hard)
 		h.writeError(w, err)
 		return
 	}
 
+	h.logger.Info("book deleted", "id", id, "hard", hard, "by", actor)
 	h.writeJSON(w, http.StatusNoContent, nil)
 }
@@ -0,0 +1,61 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+	"tspo_server/internal/query"
+	"tspo_server/model"
+)
+
+var historyListOptions = query.Options{
+	DefaultSort: "version",
+	Sortable:    []string{"version", "changed_at"},
+	Filters:     []string{"action"},
+}
+
+// GetBookHistory возвращает журнал изменений книги, в том числе удалённой безвозвратно
+func (h *Handler) GetBookHistory(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	id := r.PathValue("id")
+	params := query.NewParamsWithOptions(r, historyListOptions)
+	entries, total, err := h.repo.GetBookHistory(ctx, id, params)
+	if err != nil {
+		h.logger.Error("failed to get book history", "error", err, "id", id)
+		h.writeError(w, err)
+		return
+	}
+	if entries == nil {
+		entries = []model.BookHistoryEntry{}
+	}
+
+	h.writeJSON(w, http.StatusOK, Response{
+		Data:       entries,
+		Pagination: newPagination(params, total),
+	})
+}
+
+// GetBookRevision возвращает запись журнала и состояние книги в указанной версии
+func (h *Handler) GetBookRevision(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	id := r.PathValue("id")
+	version, err := strconv.Atoi(r.PathValue("version"))
+	if err != nil || version < 1 {
+		writeAPIError(w, http.StatusBadRequest, "Invalid version")
+		return
+	}
+
+	entry, err := h.repo.GetBookRevision(ctx, id, version)
+	if err != nil {
+		h.logger.Error("failed to get book revision", "error", err, "id", id, "version", version)
+		h.writeError(w, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, Response{Data: entry})
+}
@@ -50,6 +50,20 @@ func (w *CustomResponseWriter) Done() {
 	}
 }
 
+type requestUserKey struct{}
+
+// requestUser заполняется AuthMiddleware после проверки токена, чтобы
+// HandlerLogging, стоящий снаружи, мог записать пользователя в лог ответа
+type requestUser struct {
+	username string
+}
+
+func setRequestUser(ctx context.Context, username string) {
+	if u, ok := ctx.Value(requestUserKey{}).(*requestUser); ok {
+		u.username = username
+	}
+}
+
 func HandlerLogging(logger *slog.Logger) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -57,7 +71,8 @@ func HandlerLogging(logger *slog.Logger) func(http.Handler) http.Handler {
 			if a := r.UserAgent(); a != "" {
 				agent = fmt.Sprintf("using %s", a)
 			}
-			user := r.Header.Get("X-Remote-User")
+			user := &requestUser{}
+			r = r.WithContext(context.WithValue(r.Context(), requestUserKey{}, user))
 
 			if strings.HasPrefix(r.URL.Path, "/webdav") {
 				logger.Debug("Request received with /webdav prefix")
@@ -100,7 +115,6 @@ func HandlerLogging(logger *slog.Logger) func(http.Handler) http.Handler {
 					slog.String("request_body", bodyStr),
 					slog.String("method", r.Method),
 					slog.String("path", r.URL.Path),
-					slog.String("user", user),
 					slog.String("remote_addr", r.RemoteAddr),
 					slog.Any("headers", headerMap),
 				)
@@ -112,7 +126,6 @@ func HandlerLogging(logger *slog.Logger) func(http.Handler) http.Handler {
 					"Request received",
 					slog.String("method", r.Method),
 					slog.String("path", r.URL.Path),
-					slog.String("user", user),
 					slog.String("remote_addr", r.RemoteAddr),
 					slog.String("agent", agent),
 				)
@@ -136,7 +149,7 @@ func HandlerLogging(logger *slog.Logger) func(http.Handler) http.Handler {
 					"Response sent",
 					slog.String("method", r.Method),
 					slog.String("path", r.URL.Path),
-					slog.String("user", user),
+					slog.String("user", user.username),
 					slog.String("remote_addr", r.RemoteAddr),
 					slog.Int("status_code", ew.StatusCode),
 					slog.String("response_body", ew.body.String()),
@@ -149,7 +162,7 @@ func HandlerLogging(logger *slog.Logger) func(http.Handler) http.Handler {
 					"Response sent",
 					slog.String("method", r.Method),
 					slog.String("path", r.URL.Path),
-					slog.String("user", user),
+					slog.String("user", user.username),
 					slog.String("remote_addr", r.RemoteAddr),
 					slog.Int("status_code", ew.StatusCode),
 					slog.String("duration", duration.String()),
@@ -1,8 +1,11 @@
 package app
 
 import (
+	"encoding/json"
+	goerrors "errors"
 	"net/http"
 	"tspo_server/internal/auth"
+	"tspo_server/internal/errors"
 )
 
 type AuthMiddleware struct {
@@ -17,17 +20,99 @@ func NewAuthMiddleware(jwt *auth.JWTMiddleware) *AuthMiddleware {
 
 func (m *AuthMiddleware) RequireAuth(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		token, err := m.jwt.ValidateRequest(r)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusUnauthorized)
+		r, ok := m.authenticate(w, r)
+		if !ok {
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
+
+// RequireRole пропускает запрос, только если роль пользователя входит в список
+func (m *AuthMiddleware) RequireRole(roles []auth.Role, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r, ok := m.authenticate(w, r)
+		if !ok {
+			return
+		}
+
+		claims, _ := auth.ClaimsFromContext(r.Context())
+		for _, role := range roles {
+			if claims.Role == role {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		writeAPIError(w, http.StatusForbidden, "Insufficient role")
+	}
+}
+
+// RequirePermission пропускает запрос, только если роль пользователя даёт нужное разрешение
+func (m *AuthMiddleware) RequirePermission(perm auth.Permission, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r, ok := m.authenticate(w, r)
+		if !ok {
 			return
 		}
 
-		if m.jwt.IsBlacklisted(token) {
-			http.Error(w, "Token has been revoked", http.StatusUnauthorized)
+		claims, _ := auth.ClaimsFromContext(r.Context())
+		if !claims.Can(perm) {
+			writeAPIError(w, http.StatusForbidden, "Insufficient permissions")
 			return
 		}
 
 		next.ServeHTTP(w, r)
 	}
 }
+
+// authenticate проверяет токен или API-ключ и возвращает запрос, в контексте которого
+// лежат claims. Если запрос не аутентифицирован, пишет 401 и возвращает ok = false.
+func (m *AuthMiddleware) authenticate(w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
+	if apiKey := r.Header.Get("X-API-Key"); apiKey != "" {
+		claims, err := m.jwt.ValidateAPIKey(r.Context(), apiKey)
+		if goerrors.Is(err, auth.ErrInvalidAPIKey) {
+			writeAPIError(w, http.StatusUnauthorized, "Invalid API key")
+			return r, false
+		}
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, "Internal server error")
+			return r, false
+		}
+
+		setRequestUser(r.Context(), claims.Username)
+		return r.WithContext(auth.WithClaims(r.Context(), claims)), true
+	}
+
+	claims, err := m.jwt.ValidateRequest(r)
+	if err != nil {
+		writeAPIError(w, http.StatusUnauthorized, err.Error())
+		return r, false
+	}
+
+	revoked, err := m.jwt.IsRevoked(r.Context(), claims)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "Internal server error")
+		return r, false
+	}
+	if revoked {
+		writeAPIError(w, http.StatusUnauthorized, "Token has been revoked")
+		return r, false
+	}
+
+	setRequestUser(r.Context(), claims.Username)
+	return r.WithContext(auth.WithClaims(r.Context(), claims)), true
+}
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+func writeAPIError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, Response{
+		Error: errors.NewAPIError(status, message),
+	})
+}
@@ -0,0 +1,105 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+	"tspo_server/internal/auth"
+	"tspo_server/internal/db"
+	"tspo_server/internal/query"
+	"tspo_server/model"
+)
+
+var trashListOptions = query.Options{
+	DefaultSort: "deleted_at",
+	Sortable:    []string{"id", "title", "author", "deleted_at"},
+	Filters:     []string{"title", "author"},
+}
+
+// GetTrash возвращает книги из корзины
+func (h *Handler) GetTrash(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	params := query.NewParamsWithOptions(r, trashListOptions)
+	books, total, err := h.repo.GetDeletedBooks(ctx, params)
+	if err != nil {
+		h.logger.Error("failed to get deleted books", "error", err)
+		h.writeError(w, err)
+		return
+	}
+	if books == nil {
+		books = []model.Book{}
+	}
+	for i := range books {
+		books[i].ETag = bookETag(&books[i])
+	}
+
+	h.writeJSON(w, http.StatusOK, Response{
+		Data:       books,
+		Pagination: newPagination(params, total),
+	})
+}
+
+// RestoreBook возвращает книгу из корзины
+func (h *Handler) RestoreBook(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	id := r.PathValue("id")
+	actor := auth.UsernameFromContext(r.Context())
+	book, err := h.repo.RestoreBook(ctx, id, actor)
+	if err != nil {
+		h.logger.Error("failed to restore book", "error", err, "id", id)
+		h.writeError(w, err)
+		return
+	}
+
+	h.logger.Info("book restored", "id", id, "by", actor)
+	h.writeBook(w, http.StatusOK, book)
+}
+
+// trashJanitorActor - автор записей истории об автоматическом удалении из корзины
+const trashJanitorActor = "system"
+
+// TrashJanitor безвозвратно удаляет книги, пролежавшие в корзине дольше срока хранения
+type TrashJanitor struct {
+	repo      *db.BookRepository
+	retention time.Duration
+	logger    *slog.Logger
+}
+
+func NewTrashJanitor(repo *db.BookRepository, retention time.Duration, logger *slog.Logger) *TrashJanitor {
+	return &TrashJanitor{
+		repo:      repo,
+		retention: retention,
+		logger:    logger,
+	}
+}
+
+// Run блокируется до отмены ctx, поэтому запускается в отдельной горутине
+func (j *TrashJanitor) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			j.purge(ctx)
+		}
+	}
+}
+
+func (j *TrashJanitor) purge(ctx context.Context) {
+	purged, err := j.repo.PurgeDeletedBefore(ctx, time.Now().Add(-j.retention), trashJanitorActor)
+	if err != nil {
+		j.logger.Error("failed to purge deleted books", "error", err)
+		return
+	}
+	if purged > 0 {
+		j.logger.Info("purged deleted books", "count", purged, "retention", j.retention)
+	}
+}
@@ -0,0 +1,217 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+	"tspo_server/internal/auth"
+	"tspo_server/internal/db"
+	"tspo_server/internal/errors"
+	"tspo_server/internal/query"
+	"tspo_server/model"
+)
+
+// UserHandler - администрирование пользователей. Все методы должны быть
+// закрыты RequirePermission(auth.PermUsersManage).
+type UserHandler struct {
+	users    *db.UserRepository
+	sessions *db.SessionRepository
+	logger   *slog.Logger
+}
+
+// UpdateUserRequest - изменения пользователя; поля, которые не переданы, не меняются
+type UpdateUserRequest struct {
+	Role        *string `json:"role,omitempty"`
+	Status      *string `json:"status,omitempty"` // active или disabled
+	ForceLogout bool    `json:"force_logout,omitempty"`
+}
+
+var userListOptions = query.Options{
+	DefaultSort: "username",
+	Sortable:    []string{"id", "username", "email", "role", "status", "created_at"},
+	Filters:     []string{"username", "email", "role", "status"},
+}
+
+func NewUserHandler(users *db.UserRepository, sessions *db.SessionRepository, logger *slog.Logger) *UserHandler {
+	return &UserHandler{
+		users:    users,
+		sessions: sessions,
+		logger:   logger,
+	}
+}
+
+func (h *UserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	params := query.NewParamsWithOptions(r, userListOptions)
+	users, total, err := h.users.ListUsers(ctx, params)
+	if err != nil {
+		h.logger.Error("failed to list users", "error", err)
+		writeAPIError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	if users == nil {
+		users = []model.User{}
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Data:       users,
+		Pagination: newPagination(params, total),
+	})
+}
+
+func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	user, ok := h.loadUser(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Response{Data: user})
+}
+
+// UpdateUser меняет роль и статус пользователя. При смене роли, блокировке
+// или force_logout все сессии пользователя завершаются, чтобы изменения
+// вступили в силу сразу, а не после истечения access-токенов.
+func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var req UpdateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, ok := h.loadUser(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	var violations []errors.FieldError
+	if req.Role != nil {
+		if _, err := auth.ParseRole(*req.Role); err != nil {
+			violations = append(violations, errors.FieldError{
+				Field: "role", Code: "invalid", Message: err.Error(),
+			})
+		}
+	}
+	if req.Status != nil && *req.Status != model.UserStatusActive && *req.Status != model.UserStatusDisabled {
+		violations = append(violations, errors.FieldError{
+			Field: "status", Code: "invalid", Message: "status must be active or disabled",
+		})
+	}
+	if len(violations) > 0 {
+		writeJSON(w, http.StatusUnprocessableEntity, Response{
+			Error: errors.NewValidationAPIError(http.StatusUnprocessableEntity, "User data is invalid", violations),
+		})
+		return
+	}
+
+	revokeSessions := req.ForceLogout
+	if req.Role != nil && *req.Role != user.Role {
+		revokeSessions = true
+		user.Role = *req.Role
+	}
+	if req.Status != nil && *req.Status != user.Status {
+		revokeSessions = revokeSessions || *req.Status == model.UserStatusDisabled
+		user.Status = *req.Status
+	}
+
+	if isSelf(r, user.ID) && (user.Role != string(auth.RoleAdmin) || user.Status != model.UserStatusActive) {
+		writeAPIError(w, http.StatusConflict, "Admins cannot demote or disable their own account")
+		return
+	}
+
+	if err := h.users.UpdateUser(ctx, user); err != nil {
+		h.logger.Error("failed to update user", "error", err, "id", user.ID)
+		writeAPIError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	if revokeSessions && !h.revokeSessions(ctx, w, user) {
+		return
+	}
+
+	h.logger.Info("user updated", "id", user.ID, "role", user.Role, "status", user.Status,
+		"sessions_revoked", revokeSessions, "by", auth.UsernameFromContext(r.Context()))
+
+	writeJSON(w, http.StatusOK, Response{Data: user})
+}
+
+// DeleteUser помечает пользователя удалённым и завершает его сессии.
+// Запись остаётся в базе, чтобы сохранить ссылки на неё (например, created_by книг).
+func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	user, ok := h.loadUser(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	if isSelf(r, user.ID) {
+		writeAPIError(w, http.StatusConflict, "Admins cannot delete their own account")
+		return
+	}
+
+	user.Status = model.UserStatusDeleted
+	if err := h.users.UpdateUser(ctx, user); err != nil {
+		h.logger.Error("failed to delete user", "error", err, "id", user.ID)
+		writeAPIError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	if !h.revokeSessions(ctx, w, user) {
+		return
+	}
+
+	h.logger.Info("user deleted", "id", user.ID, "by", auth.UsernameFromContext(r.Context()))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *UserHandler) loadUser(ctx context.Context, w http.ResponseWriter, r *http.Request) (*model.User, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeAPIError(w, http.StatusNotFound, "Resource not found")
+		return nil, false
+	}
+
+	user, err := h.users.GetUserByID(ctx, id)
+	if err == errors.ErrNotFound {
+		writeAPIError(w, http.StatusNotFound, "Resource not found")
+		return nil, false
+	}
+	if err != nil {
+		h.logger.Error("failed to get user", "error", err, "id", id)
+		writeAPIError(w, http.StatusInternalServerError, "Internal server error")
+		return nil, false
+	}
+	return user, true
+}
+
+func (h *UserHandler) revokeSessions(ctx context.Context, w http.ResponseWriter, user *model.User) bool {
+	if err := h.sessions.RevokeUserSessions(ctx, user.ID); err != nil {
+		h.logger.Error("failed to revoke user sessions", "error", err, "id", user.ID)
+		writeAPIError(w, http.StatusInternalServerError, "Internal server error")
+		return false
+	}
+	return true
+}
+
+// isSelf сообщает, относится ли запрос к учётной записи самого администратора
+func isSelf(r *http.Request, userID int64) bool {
+	claims, ok := auth.ClaimsFromContext(r.Context())
+	if !ok {
+		return false
+	}
+	id, err := claims.UserID()
+	return err == nil && id == userID
+}
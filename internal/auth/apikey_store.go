@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+	"tspo_server/internal/errors"
+	"tspo_server/model"
+)
+
+type APIKeyStore interface {
+	CreateAPIKey(ctx context.Context, key *model.APIKey) error
+	GetAPIKeyByPrefix(ctx context.Context, prefix string) (*model.APIKey, error)
+	ListUserAPIKeys(ctx context.Context, userID int64) ([]model.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id, userID int64) error
+	TouchAPIKey(ctx context.Context, id int64) error
+}
+
+type MemoryAPIKeyStore struct {
+	keys   map[int64]model.APIKey
+	nextID int64
+	mu     sync.Mutex
+}
+
+func NewMemoryAPIKeyStore() *MemoryAPIKeyStore {
+	return &MemoryAPIKeyStore{
+		keys: make(map[int64]model.APIKey),
+	}
+}
+
+func (s *MemoryAPIKeyStore) CreateAPIKey(ctx context.Context, key *model.APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	key.ID = s.nextID
+	key.CreatedAt = time.Now()
+	s.keys[key.ID] = *key
+	return nil
+}
+
+func (s *MemoryAPIKeyStore) GetAPIKeyByPrefix(ctx context.Context, prefix string) (*model.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range s.keys {
+		if key.Prefix == prefix {
+			return &key, nil
+		}
+	}
+	return nil, errors.ErrNotFound
+}
+
+func (s *MemoryAPIKeyStore) ListUserAPIKeys(ctx context.Context, userID int64) ([]model.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var keys []model.APIKey
+	for _, key := range s.keys {
+		if key.UserID == userID {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (s *MemoryAPIKeyStore) RevokeAPIKey(ctx context.Context, id, userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, exists := s.keys[id]
+	if !exists || key.UserID != userID {
+		return errors.ErrNotFound
+	}
+	if key.RevokedAt == nil {
+		now := time.Now()
+		key.RevokedAt = &now
+		s.keys[id] = key
+	}
+	return nil
+}
+
+func (s *MemoryAPIKeyStore) TouchAPIKey(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, exists := s.keys[id]
+	if !exists {
+		return errors.ErrNotFound
+	}
+	now := time.Now()
+	key.LastUsedAt = &now
+	s.keys[id] = key
+	return nil
+}
@@ -0,0 +1,233 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	apperrors "tspo_server/internal/errors"
+	"tspo_server/model"
+)
+
+// Формат ключа: tspo_<prefix>_<secret>. Префикс хранится открыто и служит для
+// поиска ключа, секрет - 256 бит случайных данных, поэтому для хэша достаточно SHA-256.
+// Префикс уникален в базе: 64 бита делают совпадение практически невозможным
+// (api_keys.prefix вмещает 16 hex-символов).
+const (
+	apiKeyScheme      = "tspo"
+	apiKeyPrefixBytes = 8
+	apiKeySecretBytes = 32
+)
+
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+// ValidateAPIKey проверяет значение заголовка X-API-Key и возвращает claims
+// владельца ключа, ограниченные областями действия ключа. Для неверного,
+// просроченного или отозванного ключа возвращает ErrInvalidAPIKey.
+func (m *JWTMiddleware) ValidateAPIKey(ctx context.Context, rawKey string) (*Claims, error) {
+	parts := strings.Split(rawKey, "_")
+	if len(parts) != 3 || parts[0] != apiKeyScheme {
+		return nil, ErrInvalidAPIKey
+	}
+
+	key, err := m.apiKeys.GetAPIKeyByPrefix(ctx, parts[1])
+	if errors.Is(err, apperrors.ErrNotFound) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashToken(rawKey)), []byte(key.KeyHash)) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && time.Now().After(*key.ExpiresAt)) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	// Роль и статус берутся у владельца на момент запроса, чтобы понижение прав
+	// и блокировка сразу распространялись и на выпущенные им ключи
+	user, err := m.userStore.GetUserByID(ctx, key.UserID)
+	if errors.Is(err, apperrors.ErrNotFound) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.Status != model.UserStatusActive {
+		return nil, ErrInvalidAPIKey
+	}
+
+	if err = m.apiKeys.TouchAPIKey(ctx, key.ID); err != nil {
+		m.logger.Warn("failed to update API key last use", "error", err, "api_key", key.Prefix)
+	}
+
+	scopes := make([]Permission, 0, len(key.Scopes))
+	for _, scope := range key.Scopes {
+		scopes = append(scopes, Permission(scope))
+	}
+
+	claims := &Claims{
+		Username: user.Username,
+		Role:     Role(user.Role),
+		Scopes:   scopes,
+		APIKeyID: key.ID,
+	}
+	claims.Subject = strconv.FormatInt(user.ID, 10)
+	return claims, nil
+}
+
+// CreateAPIKey выпускает ключ для текущего пользователя. Значение ключа
+// возвращается только в этом ответе.
+func (m *JWTMiddleware) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	claims, userID, ok := m.requireInteractiveUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if violations := validateAPIKeyRequest(req, claims.Role); len(violations) > 0 {
+		writeJSONError(w, apperrors.NewValidationAPIError(http.StatusUnprocessableEntity,
+			"API key data is invalid", violations))
+		return
+	}
+
+	prefix, secret := randomHex(apiKeyPrefixBytes), randomHex(apiKeySecretBytes)
+	rawKey := fmt.Sprintf("%s_%s_%s", apiKeyScheme, prefix, secret)
+
+	key := &model.APIKey{
+		UserID:    userID,
+		Name:      req.Name,
+		Prefix:    prefix,
+		KeyHash:   hashToken(rawKey),
+		Scopes:    req.Scopes,
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err := m.apiKeys.CreateAPIKey(r.Context(), key); err != nil {
+		m.logger.Error("failed to create API key", "error", err, "user", claims.Username)
+		http.Error(w, "Error creating API key", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(CreateAPIKeyResponse{APIKey: *key, Key: rawKey})
+}
+
+func (m *JWTMiddleware) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	claims, userID, ok := m.requireInteractiveUser(w, r)
+	if !ok {
+		return
+	}
+
+	keys, err := m.apiKeys.ListUserAPIKeys(r.Context(), userID)
+	if err != nil {
+		m.logger.Error("failed to list API keys", "error", err, "user", claims.Username)
+		http.Error(w, "Error listing API keys", http.StatusInternalServerError)
+		return
+	}
+	if keys == nil {
+		keys = []model.APIKey{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]model.APIKey{"api_keys": keys})
+}
+
+func (m *JWTMiddleware) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	claims, userID, ok := m.requireInteractiveUser(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "API key not found", http.StatusNotFound)
+		return
+	}
+
+	err = m.apiKeys.RevokeAPIKey(r.Context(), id, userID)
+	if errors.Is(err, apperrors.ErrNotFound) {
+		http.Error(w, "API key not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		m.logger.Error("failed to revoke API key", "error", err, "user", claims.Username)
+		http.Error(w, "Error revoking API key", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// requireInteractiveUser как requireUser, но запрещает управлять учётной записью
+// (ключами, 2FA) по самому API-ключу
+func (m *JWTMiddleware) requireInteractiveUser(w http.ResponseWriter, r *http.Request) (*Claims, int64, bool) {
+	claims, userID, ok := m.requireUser(w, r)
+	if !ok {
+		return nil, 0, false
+	}
+	if claims.APIKeyID != 0 {
+		http.Error(w, "API keys cannot be used for this operation", http.StatusForbidden)
+		return nil, 0, false
+	}
+	return claims, userID, true
+}
+
+func validateAPIKeyRequest(req CreateAPIKeyRequest, role Role) []apperrors.FieldError {
+	var violations []apperrors.FieldError
+
+	if strings.TrimSpace(req.Name) == "" {
+		violations = append(violations, apperrors.FieldError{
+			Field: "name", Code: "required", Message: "name is required",
+		})
+	}
+	if len(req.Scopes) == 0 {
+		violations = append(violations, apperrors.FieldError{
+			Field: "scopes", Code: "required", Message: "at least one scope is required",
+		})
+	}
+	for _, scope := range req.Scopes {
+		if !role.Can(Permission(scope)) {
+			violations = append(violations, apperrors.FieldError{
+				Field: "scopes", Code: "not_allowed",
+				Message: fmt.Sprintf("scope %q is unknown or not granted to your role", scope),
+			})
+		}
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		violations = append(violations, apperrors.FieldError{
+			Field: "expires_at", Code: "in_past", Message: "expires_at must be in the future",
+		})
+	}
+
+	return violations
+}
+
+// hashToken хэширует случайные секреты (API-ключи, токены сброса). Их энтропии
+// достаточно, чтобы не использовать медленные хэши паролей.
+func hashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
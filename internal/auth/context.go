@@ -0,0 +1,24 @@
+package auth
+
+import "context"
+
+type claimsContextKey struct{}
+
+// WithClaims возвращает контекст, содержащий claims аутентифицированного пользователя
+func WithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsContextKey{}, claims)
+}
+
+// ClaimsFromContext достаёт claims, сохранённые AuthMiddleware. ok = false для анонимных запросов.
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsContextKey{}).(*Claims)
+	return claims, ok && claims != nil
+}
+
+// UsernameFromContext возвращает имя пользователя или пустую строку для анонимных запросов
+func UsernameFromContext(ctx context.Context) string {
+	if claims, ok := ClaimsFromContext(ctx); ok {
+		return claims.Username
+	}
+	return ""
+}
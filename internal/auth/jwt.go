@@ -1,59 +1,159 @@
 package auth
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/golang-jwt/jwt/v5"
+	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
+	apperrors "tspo_server/internal/errors"
+	"tspo_server/internal/notify"
+	"tspo_server/model"
 )
 
 type JWTMiddleware struct {
-	accessSecret  []byte
-	refreshSecret []byte
-	userStore     *UserStore
-	blacklist     *TokenBlacklist
+	accessKeys      *KeySet
+	refreshSecret   []byte
+	tokens          TokenConfig
+	userStore       UserStore
+	sessions        SessionStore
+	apiKeys         APIKeyStore
+	twoFactor       TwoFactorStore
+	resets          PasswordResetStore
+	resetTokenTTL   time.Duration
+	verifications   EmailVerificationStore
+	verificationTTL time.Duration
+	notifier        notify.Notifier
+	hasher          *PasswordHasher
+	passwordPolicy  PasswordPolicy
+	revocations     *RevocationList
+	limiter         *LoginLimiter
+	oidc            *OIDCProvider
+	identities      IdentityStore
+	logger          *slog.Logger
+
+	dummyHashOnce sync.Once
+	dummyHash     string
 }
 
-type UserStore struct {
-	users map[string]string // username -> password hash
-	mu    sync.RWMutex
+// Options - зависимости и настройки JWTMiddleware
+type Options struct {
+	AccessSecret    string
+	AccessKeys      *KeySet // если не задан, access-токены подписываются HS256 с AccessSecret
+	RefreshSecret   string
+	Tokens          TokenConfig
+	UserStore       UserStore
+	Sessions        SessionStore
+	APIKeys         APIKeyStore
+	TwoFactor       TwoFactorStore
+	PasswordResets  PasswordResetStore
+	ResetTokenTTL   time.Duration // время жизни токена сброса пароля, по умолчанию 30 минут
+	Verifications   EmailVerificationStore
+	VerificationTTL time.Duration // время жизни токена подтверждения email, по умолчанию 24 часа
+	Notifier        notify.Notifier
+	Hasher          *PasswordHasher
+	PasswordPolicy  PasswordPolicy
+	Revocations     *RevocationList
+	LoginLimiter    *LoginLimiter
+	OIDC            *OIDCProvider // nil, если вход через OIDC не настроен
+	Identities      IdentityStore
+	Logger          *slog.Logger
 }
 
-type TokenBlacklist struct {
-	tokens map[string]time.Time
-	mu     sync.RWMutex
+// TokenConfig - время жизни токенов и значения, которые проставляются в них и
+// проверяются при разборе. Нулевые поля заменяются значениями по умолчанию.
+type TokenConfig struct {
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+	Issuer     string
+	Audience   string
+	ClockSkew  time.Duration // допустимое расхождение часов при проверке exp/nbf/iat
 }
 
-func (m *JWTMiddleware) ValidateRequest(r *http.Request) (string, error) {
-	return m.extractAndValidateToken(r)
+func (c TokenConfig) withDefaults() TokenConfig {
+	if c.AccessTTL <= 0 {
+		c.AccessTTL = 15 * time.Minute
+	}
+	if c.RefreshTTL <= 0 {
+		c.RefreshTTL = 7 * 24 * time.Hour
+	}
+	if c.Issuer == "" {
+		c.Issuer = "tspo_server"
+	}
+	if c.Audience == "" {
+		c.Audience = "tspo_server"
+	}
+	return c
 }
 
-func (m *JWTMiddleware) IsBlacklisted(token string) bool {
-	return m.blacklist.IsBlacklisted(token)
+func (m *JWTMiddleware) ValidateRequest(r *http.Request) (*Claims, error) {
+	return m.extractAndValidateToken(r)
 }
 
-func NewJWTMiddleware(accessSecret, refreshSecret string) *JWTMiddleware {
-	return &JWTMiddleware{
-		accessSecret:  []byte(accessSecret),
-		refreshSecret: []byte(refreshSecret),
-		userStore:     NewUserStore(),
-		blacklist:     NewTokenBlacklist(),
+// IsRevoked проверяет, отозван ли сам access-токен или сессия, к которой он относится
+func (m *JWTMiddleware) IsRevoked(ctx context.Context, claims *Claims) (bool, error) {
+	revoked, err := m.revocations.IsRevoked(ctx, claims.ID)
+	if err != nil || revoked {
+		return revoked, err
 	}
-}
 
-func NewUserStore() *UserStore {
-	return &UserStore{
-		users: make(map[string]string),
+	session, err := m.sessions.GetSession(ctx, claims.SessionID)
+	if errors.Is(err, apperrors.ErrNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
 	}
+	return session.RevokedAt != nil, nil
 }
 
-func NewTokenBlacklist() *TokenBlacklist {
-	return &TokenBlacklist{
-		tokens: make(map[string]time.Time),
+func NewJWTMiddleware(opts Options) *JWTMiddleware {
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	resetTokenTTL := opts.ResetTokenTTL
+	if resetTokenTTL <= 0 {
+		resetTokenTTL = 30 * time.Minute
+	}
+	verificationTTL := opts.VerificationTTL
+	if verificationTTL <= 0 {
+		verificationTTL = 24 * time.Hour
+	}
+	notifier := opts.Notifier
+	if notifier == nil {
+		notifier = notify.NewLogNotifier(logger)
+	}
+	accessKeys := opts.AccessKeys
+	if accessKeys == nil {
+		accessKeys = NewHMACKeySet([]byte(opts.AccessSecret))
+	}
+	return &JWTMiddleware{
+		accessKeys:      accessKeys,
+		refreshSecret:   []byte(opts.RefreshSecret),
+		tokens:          opts.Tokens.withDefaults(),
+		userStore:       opts.UserStore,
+		sessions:        opts.Sessions,
+		apiKeys:         opts.APIKeys,
+		twoFactor:       opts.TwoFactor,
+		resets:          opts.PasswordResets,
+		resetTokenTTL:   resetTokenTTL,
+		verifications:   opts.Verifications,
+		verificationTTL: verificationTTL,
+		notifier:        notifier,
+		hasher:          opts.Hasher,
+		passwordPolicy:  opts.PasswordPolicy,
+		revocations:     opts.Revocations,
+		limiter:         opts.LoginLimiter,
+		oidc:            opts.OIDC,
+		identities:      opts.Identities,
+		logger:          logger,
 	}
 }
 
@@ -64,35 +164,74 @@ func (m *JWTMiddleware) Middleware(next http.Handler) http.Handler {
 			return
 		}
 
-		token, err := m.extractAndValidateToken(r)
+		claims, err := m.extractAndValidateToken(r)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusUnauthorized)
 			return
 		}
 
-		if m.blacklist.IsBlacklisted(token) {
+		revoked, err := m.IsRevoked(r.Context(), claims)
+		if err != nil {
+			http.Error(w, "Error validating token", http.StatusInternalServerError)
+			return
+		}
+		if revoked {
 			http.Error(w, "Token has been revoked", http.StatusUnauthorized)
 			return
 		}
 
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(w, r.WithContext(WithClaims(r.Context(), claims)))
 	})
 }
 
 func (m *JWTMiddleware) Register(w http.ResponseWriter, r *http.Request) {
-	var creds Credentials
-	if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
+	var req RegisterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	if err := m.userStore.AddUser(creds.Username, creds.Password); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	req.Email = strings.TrimSpace(req.Email)
+	violations := m.passwordPolicy.Validate(req.Username, req.Password)
+	violations = append(violations, validateEmail(req.Email)...)
+	if len(violations) > 0 {
+		writeJSONError(w, apperrors.NewValidationAPIError(http.StatusUnprocessableEntity,
+			"Registration data is invalid", violations))
+		return
+	}
+
+	hash, err := m.hasher.Hash(req.Password)
+	if err != nil {
+		http.Error(w, "Error registering user", http.StatusInternalServerError)
 		return
 	}
 
+	// Учётная запись активируется после подтверждения email
+	user := &model.User{
+		Username:     req.Username,
+		Email:        req.Email,
+		PasswordHash: hash,
+		Role:         string(RoleReader),
+		Status:       model.UserStatusPending,
+	}
+	if err := m.userStore.CreateUser(r.Context(), user); err != nil {
+		if errors.Is(err, apperrors.ErrUserExists) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Error registering user", http.StatusInternalServerError)
+		return
+	}
+
+	// Если письмо не ушло, пользователь может запросить его повторно
+	if err := m.sendVerification(r.Context(), user); err != nil {
+		m.logger.Error("failed to send verification email", "error", err, "user", user.Username)
+	}
+
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{"message": "User registered successfully"})
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "User registered successfully, check your email to verify the account",
+	})
 }
 
 func (m *JWTMiddleware) Login(w http.ResponseWriter, r *http.Request) {
@@ -102,12 +241,82 @@ func (m *JWTMiddleware) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !m.userStore.ValidateUser(creds.Username, creds.Password) {
+	ctx := r.Context()
+	ip := clientIP(r)
+
+	lockedUntil, err := m.limiter.LockedUntil(ctx, userAttemptKey(creds.Username), ipAttemptKey(ip))
+	if err != nil {
+		m.logger.Error("failed to check login lockout", "error", err)
+		http.Error(w, "Error validating credentials", http.StatusInternalServerError)
+		return
+	}
+	if !lockedUntil.IsZero() {
+		writeLockedOut(w, lockedUntil)
+		return
+	}
+
+	user, err := m.userStore.GetUserByUsername(ctx, creds.Username)
+	if err != nil && !errors.Is(err, apperrors.ErrNotFound) {
+		http.Error(w, "Error validating credentials", http.StatusInternalServerError)
+		return
+	}
+
+	// Для несуществующего пользователя всё равно проверяем пароль против
+	// фиктивного хэша, чтобы время ответа не выдавало наличие учётной записи.
+	// То же для пользователей без пароля, созданных входом через OIDC.
+	passwordHash := m.getDummyHash()
+	if user != nil && user.PasswordHash != "" {
+		passwordHash = user.PasswordHash
+	}
+
+	ok, needsRehash, err := m.hasher.Verify(passwordHash, creds.Password)
+	if err != nil {
+		m.logger.Error("failed to verify password hash", "error", err, "user", creds.Username)
+		http.Error(w, "Error validating credentials", http.StatusInternalServerError)
+		return
+	}
+	// Удалённая учётная запись неотличима от несуществующей
+	if !ok || user == nil || user.PasswordHash == "" || user.Status == model.UserStatusDeleted {
+		delay, err := m.limiter.RecordFailure(ctx, creds.Username, ip)
+		if err != nil {
+			m.logger.Error("failed to record login failure", "error", err)
+		}
+		sleepContext(ctx, delay)
 		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
 		return
 	}
 
-	tokens, err := m.generateTokenPair(creds.Username)
+	// Статус проверяется только после верного пароля, чтобы не раскрывать его подбором
+	if apiErr := checkAccountStatus(user); apiErr != nil {
+		writeJSONError(w, apiErr)
+		return
+	}
+
+	if err = m.limiter.Reset(ctx, userAttemptKey(user.Username)); err != nil {
+		m.logger.Warn("failed to reset login failures", "error", err, "user", user.Username)
+	}
+	if needsRehash {
+		m.rehashPassword(ctx, user, creds.Password)
+	}
+
+	twoFactor, err := m.twoFactorEnabled(r, user)
+	if err != nil {
+		m.logger.Error("failed to check two-factor settings", "error", err, "user", user.Username)
+		http.Error(w, "Error validating credentials", http.StatusInternalServerError)
+		return
+	}
+	if twoFactor {
+		challenge, err := m.newChallengeToken(user)
+		if err != nil {
+			http.Error(w, "Error generating tokens", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(challenge)
+		return
+	}
+
+	tokens, err := m.generateTokenPair(r, user, "")
 	if err != nil {
 		http.Error(w, "Error generating tokens", http.StatusInternalServerError)
 		return
@@ -117,6 +326,59 @@ func (m *JWTMiddleware) Login(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(tokens)
 }
 
+// BootstrapAdmin создаёт учётную запись администратора, если её ещё нет.
+// Вызывается при старте сервера, иначе выдать роли librarian/admin было бы некому.
+func (m *JWTMiddleware) BootstrapAdmin(ctx context.Context, username, password string) error {
+	_, err := m.userStore.GetUserByUsername(ctx, username)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, apperrors.ErrNotFound) {
+		return err
+	}
+
+	hash, err := m.hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	err = m.userStore.CreateUser(ctx, &model.User{
+		Username:     username,
+		PasswordHash: hash,
+		Role:         string(RoleAdmin),
+		Status:       model.UserStatusActive,
+	})
+	if errors.Is(err, apperrors.ErrUserExists) {
+		return nil
+	}
+	return err
+}
+
+// getDummyHash лениво вычисляет хэш с текущими настройками хэширования
+func (m *JWTMiddleware) getDummyHash() string {
+	m.dummyHashOnce.Do(func() {
+		hash, err := m.hasher.Hash(randomHex(16))
+		if err != nil {
+			m.logger.Error("failed to compute dummy password hash", "error", err)
+			return
+		}
+		m.dummyHash = hash
+	})
+	return m.dummyHash
+}
+
+// rehashPassword пересчитывает хэш с текущими настройками. Ошибка не мешает входу,
+// хэш будет обновлён при следующем успешном логине.
+func (m *JWTMiddleware) rehashPassword(ctx context.Context, user *model.User, password string) {
+	hash, err := m.hasher.Hash(password)
+	if err == nil {
+		err = m.userStore.UpdatePasswordHash(ctx, user.ID, hash)
+	}
+	if err != nil {
+		m.logger.Warn("failed to rehash password", "error", err, "user", user.Username)
+	}
+}
+
 func (m *JWTMiddleware) RefreshToken(w http.ResponseWriter, r *http.Request) {
 	var refreshReq RefreshRequest
 	if err := json.NewDecoder(r.Body).Decode(&refreshReq); err != nil {
@@ -125,8 +387,11 @@ func (m *JWTMiddleware) RefreshToken(w http.ResponseWriter, r *http.Request) {
 	}
 
 	token, err := jwt.ParseWithClaims(refreshReq.RefreshToken, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
 		return m.refreshSecret, nil
-	})
+	}, m.parserOptions()...)
 
 	if err != nil || !token.Valid {
 		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
@@ -134,12 +399,64 @@ func (m *JWTMiddleware) RefreshToken(w http.ResponseWriter, r *http.Request) {
 	}
 
 	claims, ok := token.Claims.(*Claims)
-	if !ok {
+	if !ok || claims.ID == "" || claims.SessionID == "" {
 		http.Error(w, "Invalid token claims", http.StatusUnauthorized)
 		return
 	}
 
-	tokens, err := m.generateTokenPair(claims.Username)
+	ctx := r.Context()
+
+	session, err := m.sessions.GetSession(ctx, claims.SessionID)
+	if errors.Is(err, apperrors.ErrNotFound) || (err == nil && session.RevokedAt != nil) {
+		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error generating tokens", http.StatusInternalServerError)
+		return
+	}
+
+	// Каждый refresh-токен одноразовый. Повторное предъявление означает, что токен
+	// утёк, поэтому отзываем всю сессию - и у злоумышленника, и у владельца.
+	_, err = m.sessions.ConsumeRefreshToken(ctx, claims.ID)
+	if errors.Is(err, apperrors.ErrTokenReused) {
+		m.logger.Warn("refresh token reuse detected, revoking session",
+			"user", claims.Username,
+			"session_id", claims.SessionID,
+			"jti", claims.ID,
+			"remote_addr", r.RemoteAddr,
+		)
+		if err = m.sessions.RevokeSession(ctx, claims.SessionID); err != nil {
+			m.logger.Error("failed to revoke session", "error", err, "session_id", claims.SessionID)
+		}
+		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+	if errors.Is(err, apperrors.ErrNotFound) {
+		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error generating tokens", http.StatusInternalServerError)
+		return
+	}
+
+	// Роль берётся из хранилища, чтобы изменения прав вступали в силу при обновлении токена
+	user, err := m.userStore.GetUserByUsername(ctx, claims.Username)
+	if errors.Is(err, apperrors.ErrNotFound) || (err == nil && user.Status == model.UserStatusDeleted) {
+		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error generating tokens", http.StatusInternalServerError)
+		return
+	}
+	if apiErr := checkAccountStatus(user); apiErr != nil {
+		writeJSONError(w, apiErr)
+		return
+	}
+
+	tokens, err := m.generateTokenPair(r, user, claims.SessionID)
 	if err != nil {
 		http.Error(w, "Error generating tokens", http.StatusInternalServerError)
 		return
@@ -150,38 +467,88 @@ func (m *JWTMiddleware) RefreshToken(w http.ResponseWriter, r *http.Request) {
 }
 
 func (m *JWTMiddleware) Logout(w http.ResponseWriter, r *http.Request) {
-	token, err := m.extractAndValidateToken(r)
+	claims, err := m.extractAndValidateToken(r)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusUnauthorized)
 		return
 	}
 
-	m.blacklist.Add(token, time.Now().Add(24*time.Hour))
+	if err = m.revocations.Revoke(r.Context(), claims.ID, claims.ExpiresAt.Time); err != nil {
+		m.logger.Error("failed to revoke token", "error", err, "user", claims.Username)
+		http.Error(w, "Error revoking token", http.StatusInternalServerError)
+		return
+	}
+
+	// Вместе с access-токеном завершаем сессию, иначе refresh-токен продолжит работать
+	if err = m.sessions.RevokeSession(r.Context(), claims.SessionID); err != nil && !errors.Is(err, apperrors.ErrNotFound) {
+		m.logger.Error("failed to revoke session", "error", err, "session_id", claims.SessionID)
+		http.Error(w, "Error revoking token", http.StatusInternalServerError)
+		return
+	}
 
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"message": "Logged out successfully"})
 }
 
-func (m *JWTMiddleware) generateTokenPair(username string) (*TokenResponse, error) {
+// generateTokenPair выдаёт новую пару токенов. Пустой sessionID означает новый логин,
+// для которого заводится новая сессия; иначе сессия продлевается.
+func (m *JWTMiddleware) generateTokenPair(r *http.Request, user *model.User, sessionID string) (*TokenResponse, error) {
+	ctx := r.Context()
+	now := time.Now()
+	refreshExpiresAt := now.Add(m.tokens.RefreshTTL)
+
+	if sessionID == "" {
+		session := &model.Session{
+			ID:        newTokenID(),
+			UserID:    user.ID,
+			UserAgent: r.UserAgent(),
+			IPAddress: clientIP(r),
+			ExpiresAt: refreshExpiresAt,
+		}
+		if err := m.sessions.CreateSession(ctx, session); err != nil {
+			return nil, err
+		}
+		sessionID = session.ID
+	} else if err := m.sessions.TouchSession(ctx, sessionID, clientIP(r), r.UserAgent(), refreshExpiresAt); err != nil {
+		return nil, err
+	}
 
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
-		Username: username,
+	accessTokenString, err := m.accessKeys.Sign(Claims{
+		Username:  user.Username,
+		Role:      Role(user.Role),
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(15 * time.Minute)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ID:        newTokenID(),
+			Subject:   strconv.FormatInt(user.ID, 10),
+			Issuer:    m.tokens.Issuer,
+			Audience:  jwt.ClaimStrings{m.tokens.Audience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(m.tokens.AccessTTL)),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	})
-
-	accessTokenString, err := accessToken.SignedString(m.accessSecret)
 	if err != nil {
 		return nil, err
 	}
 
+	refresh := &model.RefreshToken{
+		ID:        newTokenID(),
+		SessionID: sessionID,
+		ExpiresAt: refreshExpiresAt,
+	}
+	// Refresh-токены проверяет только этот сервис, поэтому они подписываются
+	// отдельным секретом и не могут быть приняты как access-токены по JWKS
 	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
-		Username: username,
+		Username:  user.Username,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(7 * 24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ID:        refresh.ID,
+			Subject:   strconv.FormatInt(user.ID, 10),
+			Issuer:    m.tokens.Issuer,
+			Audience:  jwt.ClaimStrings{m.tokens.Audience},
+			ExpiresAt: jwt.NewNumericDate(refresh.ExpiresAt),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	})
 
@@ -190,81 +557,69 @@ func (m *JWTMiddleware) generateTokenPair(username string) (*TokenResponse, erro
 		return nil, err
 	}
 
+	if err = m.sessions.SaveRefreshToken(ctx, refresh); err != nil {
+		return nil, err
+	}
+
 	return &TokenResponse{
 		AccessToken:  accessTokenString,
 		RefreshToken: refreshTokenString,
 		TokenType:    "Bearer",
-		ExpiresIn:    900, // 15 minutes in seconds
+		ExpiresIn:    int64(m.tokens.AccessTTL.Seconds()),
 	}, nil
 }
 
-func (m *JWTMiddleware) extractAndValidateToken(r *http.Request) (string, error) {
+func (m *JWTMiddleware) extractAndValidateToken(r *http.Request) (*Claims, error) {
 	authHeader := r.Header.Get("Authorization")
 	if authHeader == "" {
-		return "", errors.New("authorization header required")
+		return nil, errors.New("authorization header required")
 	}
 
 	bearerToken := strings.Split(authHeader, " ")
 	if len(bearerToken) != 2 || bearerToken[0] != "Bearer" {
-		return "", errors.New("invalid token format")
+		return nil, errors.New("invalid token format")
 	}
 
-	token, err := jwt.ParseWithClaims(bearerToken[1], &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return m.accessSecret, nil
-	})
+	token, err := jwt.ParseWithClaims(bearerToken[1], &Claims{}, m.accessKeys.Keyfunc, m.parserOptions()...)
 
 	if err != nil || !token.Valid {
-		return "", errors.New("invalid token")
+		return nil, errors.New("invalid token")
 	}
 
-	return bearerToken[1], nil
-}
-
-func (s *UserStore) AddUser(username, password string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	if _, exists := s.users[username]; exists {
-		return errors.New("user already exists")
+	claims, ok := token.Claims.(*Claims)
+	if !ok || claims.ID == "" || claims.ExpiresAt == nil {
+		return nil, errors.New("invalid token claims")
 	}
 
-	s.users[username] = password
-	return nil
+	return claims, nil
 }
 
-func (s *UserStore) ValidateUser(username, password string) bool {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	storedPassword, exists := s.users[username]
-	return exists && storedPassword == password
+// parserOptions включает проверку iss/aud и обязательного exp. exp, nbf и iat
+// сверяются с текущим временем с учётом допустимого расхождения часов.
+func (m *JWTMiddleware) parserOptions() []jwt.ParserOption {
+	return []jwt.ParserOption{
+		jwt.WithIssuer(m.tokens.Issuer),
+		jwt.WithAudience(m.tokens.Audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(m.tokens.ClockSkew),
+	}
 }
 
-func (b *TokenBlacklist) Add(token string, expiresAt time.Time) {
-	b.mu.Lock()
-	defer b.mu.Unlock()
-	b.tokens[token] = expiresAt
+// JWKS публикует открытые ключи проверки access-токенов для других сервисов
+func (m *JWTMiddleware) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(m.accessKeys.JWKS())
 }
 
-func (b *TokenBlacklist) IsBlacklisted(token string) bool {
-	b.mu.RLock()
-	defer b.mu.RUnlock()
-
-	expiry, exists := b.tokens[token]
-	if !exists {
-		return false
-	}
-
-	if time.Now().After(expiry) {
-		b.mu.RUnlock()
-		b.mu.Lock()
-		delete(b.tokens, token)
-		b.mu.Unlock()
-		return false
-	}
+// newTokenID генерирует случайный идентификатор для jti и сессий
+func newTokenID() string {
+	return randomHex(16)
+}
 
-	return true
+func writeJSONError(w http.ResponseWriter, apiErr *apperrors.APIError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(apiErr.Code)
+	json.NewEncoder(w).Encode(map[string]*apperrors.APIError{"error": apiErr})
 }
@@ -0,0 +1,341 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"math/big"
+	"os"
+	"sort"
+)
+
+// SigningKey - ключ подписи/проверки access-токенов. Для ключей, которые
+// используются только для проверки (после ротации), private == nil.
+type SigningKey struct {
+	ID      string // kid
+	Method  jwt.SigningMethod
+	private interface{}
+	public  interface{}
+}
+
+// KeySet подписывает access-токены активным ключом и проверяет их любым из
+// известных ключей по kid. Порядок ротации: новый ключ сначала добавляется
+// в список ключей проверки (и попадает в JWKS), затем становится активным,
+// а старый остаётся в списке проверки, пока не истекут выданные им токены.
+type KeySet struct {
+	active *SigningKey
+	keys   map[string]*SigningKey
+}
+
+// NewHMACKeySet используется, когда асимметричные ключи не настроены.
+// Такие токены могут проверить только сервисы, знающие секрет, и в JWKS они не публикуются.
+func NewHMACKeySet(secret []byte) *KeySet {
+	key := &SigningKey{
+		ID:      "hs256",
+		Method:  jwt.SigningMethodHS256,
+		private: secret,
+		public:  secret,
+	}
+	return &KeySet{
+		active: key,
+		keys:   map[string]*SigningKey{key.ID: key},
+	}
+}
+
+// LoadKeySet читает активный закрытый ключ и дополнительные ключи проверки
+// (открытые или закрытые) из PEM-файлов.
+func LoadKeySet(signingKeyFile string, verificationKeyFiles []string) (*KeySet, error) {
+	active, err := loadKeyFile(signingKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if active.private == nil {
+		return nil, fmt.Errorf("%s: signing key must be a private key", signingKeyFile)
+	}
+
+	ks := &KeySet{
+		active: active,
+		keys:   map[string]*SigningKey{active.ID: active},
+	}
+	for _, file := range verificationKeyFiles {
+		key, err := loadKeyFile(file)
+		if err != nil {
+			return nil, err
+		}
+		ks.keys[key.ID] = key
+	}
+	return ks, nil
+}
+
+// NewKeySetFromSigner создаёт набор из одного ключа, например сгенерированного в памяти
+func NewKeySetFromSigner(signer crypto.Signer) (*KeySet, error) {
+	method, err := signingMethodFor(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	jwk, _ := publicJWK(signer.Public())
+	key := &SigningKey{
+		ID:      jwk.thumbprint(),
+		Method:  method,
+		private: signer,
+		public:  signer.Public(),
+	}
+	return &KeySet{
+		active: key,
+		keys:   map[string]*SigningKey{key.ID: key},
+	}, nil
+}
+
+// Sign подписывает claims активным ключом и проставляет kid в заголовок
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.active.Method, claims)
+	token.Header["kid"] = ks.active.ID
+	return token.SignedString(ks.active.private)
+}
+
+// Keyfunc выбирает ключ проверки по kid и сверяет алгоритм, чтобы токен
+// нельзя было подписать другим методом (например, HS256 открытым RSA-ключом).
+func (ks *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key: %q", kid)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.public, nil
+}
+
+// JWKS возвращает открытые ключи в формате RFC 7517. HMAC-ключи не публикуются.
+func (ks *KeySet) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range ks.keys {
+		if jwk, ok := publicJWK(key.public); ok {
+			jwk.Kid = key.ID
+			jwk.Alg = key.Method.Alg()
+			jwk.Use = "sig"
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+func loadKeyFile(path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM block found", path)
+	}
+
+	private, public, err := parsePEMBlock(block)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	method, err := signingMethodFor(public)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	jwk, _ := publicJWK(public)
+	return &SigningKey{
+		ID:      jwk.thumbprint(),
+		Method:  method,
+		private: private,
+		public:  public,
+	}, nil
+}
+
+func parsePEMBlock(block *pem.Block) (private crypto.Signer, public crypto.PublicKey, err error) {
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, nil, err
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, nil, errors.New("unsupported private key type")
+		}
+		return signer, signer.Public(), nil
+	case "RSA PRIVATE KEY":
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, nil, err
+		}
+		return key, key.Public(), nil
+	case "EC PRIVATE KEY":
+		key, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, nil, err
+		}
+		return key, key.Public(), nil
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		return nil, key, err
+	case "RSA PUBLIC KEY":
+		key, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		return nil, key, err
+	}
+	return nil, nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+}
+
+func signingMethodFor(public crypto.PublicKey) (jwt.SigningMethod, error) {
+	switch key := public.(type) {
+	case *rsa.PublicKey:
+		return jwt.SigningMethodRS256, nil
+	case *ecdsa.PublicKey:
+		switch key.Curve {
+		case elliptic.P256():
+			return jwt.SigningMethodES256, nil
+		case elliptic.P384():
+			return jwt.SigningMethodES384, nil
+		case elliptic.P521():
+			return jwt.SigningMethodES512, nil
+		}
+		return nil, errors.New("unsupported elliptic curve")
+	case ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA, nil
+	}
+	return nil, fmt.Errorf("unsupported public key type %T", public)
+}
+
+func publicJWK(public interface{}) (JWK, bool) {
+	enc := base64.RawURLEncoding
+	switch key := public.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA",
+			N:   enc.EncodeToString(key.N.Bytes()),
+			E:   enc.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}, true
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		return JWK{
+			Kty: "EC",
+			Crv: key.Curve.Params().Name,
+			X:   enc.EncodeToString(key.X.FillBytes(make([]byte, size))),
+			Y:   enc.EncodeToString(key.Y.FillBytes(make([]byte, size))),
+		}, true
+	case ed25519.PublicKey:
+		return JWK{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   enc.EncodeToString(key),
+		}, true
+	}
+	return JWK{}, false
+}
+
+// PublicKey восстанавливает открытый ключ из JWK (нужен для проверки токенов внешнего IdP)
+func (k JWK) PublicKey() (crypto.PublicKey, error) {
+	dec := base64.RawURLEncoding
+	switch k.Kty {
+	case "RSA":
+		n, err := dec.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := dec.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported elliptic curve %q", k.Crv)
+		}
+		x, err := dec.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := dec.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported OKP curve %q", k.Crv)
+		}
+		x, err := dec.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key size")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// thumbprint вычисляет JWK Thumbprint (RFC 7638), который используется как kid
+func (k JWK) thumbprint() string {
+	var members interface{}
+	switch k.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{k.E, k.Kty, k.N}
+	case "EC":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{k.Crv, k.Kty, k.X, k.Y}
+	default:
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{k.Crv, k.Kty, k.X}
+	}
+
+	data, _ := json.Marshal(members)
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,226 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+	apperrors "tspo_server/internal/errors"
+	"tspo_server/model"
+)
+
+// LoginAttemptStore хранит счётчики неудачных входов. RecordFailure должен
+// атомарно увеличивать счётчик, начиная новое окно, если текущее истекло.
+type LoginAttemptStore interface {
+	GetAttempt(ctx context.Context, key string) (*model.LoginAttempt, error)
+	RecordFailure(ctx context.Context, key string, window time.Duration) (*model.LoginAttempt, error)
+	Lock(ctx context.Context, key string, until time.Time) error
+	Reset(ctx context.Context, key string) error
+}
+
+type LockoutConfig struct {
+	MaxUserFailures int           // неудачных попыток на имя пользователя в окне до блокировки
+	MaxIPFailures   int           // то же для IP-адреса; выше, так как IP может быть общим
+	Window          time.Duration // окно подсчёта неудачных попыток
+	LockoutDuration time.Duration
+	BaseDelay       time.Duration // задержка ответа после первой неудачи, удваивается с каждой следующей
+	MaxDelay        time.Duration
+}
+
+// LoginLimiter замедляет и временно блокирует подбор паролей.
+// Блокировка применяется к ключу, а не к учётной записи, поэтому её наличие
+// не раскрывает, существует ли пользователь.
+type LoginLimiter struct {
+	store  LoginAttemptStore
+	cfg    LockoutConfig
+	logger *slog.Logger
+}
+
+func NewLoginLimiter(store LoginAttemptStore, cfg LockoutConfig, logger *slog.Logger) *LoginLimiter {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &LoginLimiter{store: store, cfg: cfg, logger: logger}
+}
+
+func userAttemptKey(username string) string { return "user:" + username }
+func ipAttemptKey(ip string) string         { return "ip:" + ip }
+
+// LockedUntil возвращает максимальное время блокировки среди ключей
+// или нулевое время, если ни один ключ не заблокирован
+func (l *LoginLimiter) LockedUntil(ctx context.Context, keys ...string) (time.Time, error) {
+	var until time.Time
+	now := time.Now()
+	for _, key := range keys {
+		attempt, err := l.store.GetAttempt(ctx, key)
+		if errors.Is(err, apperrors.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return time.Time{}, err
+		}
+		if attempt.LockedUntil != nil && attempt.LockedUntil.After(now) && attempt.LockedUntil.After(until) {
+			until = *attempt.LockedUntil
+		}
+	}
+	return until, nil
+}
+
+// RecordFailure учитывает неудачную попытку для имени пользователя и IP,
+// блокирует ключи, превысившие лимит, и возвращает задержку перед ответом.
+func (l *LoginLimiter) RecordFailure(ctx context.Context, username, ip string) (time.Duration, error) {
+	limits := map[string]int{
+		userAttemptKey(username): l.cfg.MaxUserFailures,
+		ipAttemptKey(ip):         l.cfg.MaxIPFailures,
+	}
+
+	maxFailures := 0
+	for key, limit := range limits {
+		attempt, err := l.store.RecordFailure(ctx, key, l.cfg.Window)
+		if err != nil {
+			return 0, err
+		}
+		if attempt.Failures > maxFailures {
+			maxFailures = attempt.Failures
+		}
+
+		if limit > 0 && attempt.Failures >= limit {
+			until := time.Now().Add(l.cfg.LockoutDuration)
+			if err = l.store.Lock(ctx, key, until); err != nil {
+				return 0, err
+			}
+			l.logger.Warn("login locked after repeated failures",
+				"key", key, "failures", attempt.Failures, "locked_until", until)
+		}
+	}
+
+	return l.delay(maxFailures), nil
+}
+
+// Reset сбрасывает счётчик ключа: после успешного входа и при ручной разблокировке
+func (l *LoginLimiter) Reset(ctx context.Context, key string) error {
+	return l.store.Reset(ctx, key)
+}
+
+func (l *LoginLimiter) delay(failures int) time.Duration {
+	if failures <= 0 || l.cfg.BaseDelay <= 0 {
+		return 0
+	}
+	delay := l.cfg.BaseDelay
+	for i := 1; i < failures && delay < l.cfg.MaxDelay; i++ {
+		delay *= 2
+	}
+	if l.cfg.MaxDelay > 0 && delay > l.cfg.MaxDelay {
+		delay = l.cfg.MaxDelay
+	}
+	return delay
+}
+
+// sleepContext ждёт d или до отмены запроса клиентом
+func sleepContext(ctx context.Context, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
+func writeLockedOut(w http.ResponseWriter, until time.Time) {
+	retryAfter := int(time.Until(until).Seconds()) + 1
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	http.Error(w, "Too many failed login attempts, try again later", http.StatusTooManyRequests)
+}
+
+// UnlockLogin снимает блокировку входа с имени пользователя и/или IP-адреса.
+// Предназначен для администраторов.
+func (m *JWTMiddleware) UnlockLogin(w http.ResponseWriter, r *http.Request) {
+	var req UnlockRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || (req.Username == "" && req.IP == "") {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var keys []string
+	if req.Username != "" {
+		keys = append(keys, userAttemptKey(req.Username))
+	}
+	if req.IP != "" {
+		keys = append(keys, ipAttemptKey(req.IP))
+	}
+
+	for _, key := range keys {
+		if err := m.limiter.Reset(r.Context(), key); err != nil && !errors.Is(err, apperrors.ErrNotFound) {
+			m.logger.Error("failed to unlock login", "error", err, "key", key)
+			http.Error(w, "Error unlocking login", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	m.logger.Info("login unlocked", "keys", keys, "by", UsernameFromContext(r.Context()))
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Login unlocked"})
+}
+
+type MemoryLoginAttemptStore struct {
+	attempts map[string]model.LoginAttempt
+	mu       sync.Mutex
+}
+
+func NewMemoryLoginAttemptStore() *MemoryLoginAttemptStore {
+	return &MemoryLoginAttemptStore{
+		attempts: make(map[string]model.LoginAttempt),
+	}
+}
+
+func (s *MemoryLoginAttemptStore) GetAttempt(ctx context.Context, key string) (*model.LoginAttempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempt, exists := s.attempts[key]
+	if !exists {
+		return nil, apperrors.ErrNotFound
+	}
+	return &attempt, nil
+}
+
+func (s *MemoryLoginAttemptStore) RecordFailure(ctx context.Context, key string, window time.Duration) (*model.LoginAttempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	attempt, exists := s.attempts[key]
+	if !exists || now.Sub(attempt.WindowStart) > window {
+		attempt = model.LoginAttempt{Key: key, WindowStart: now, LockedUntil: attempt.LockedUntil}
+	}
+	attempt.Failures++
+	s.attempts[key] = attempt
+	return &attempt, nil
+}
+
+func (s *MemoryLoginAttemptStore) Lock(ctx context.Context, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempt := s.attempts[key]
+	attempt.Key = key
+	attempt.LockedUntil = &until
+	s.attempts[key] = attempt
+	return nil
+}
+
+func (s *MemoryLoginAttemptStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.attempts, key)
+	return nil
+}
@@ -1,17 +1,54 @@
 package auth
 
-import "github.com/golang-jwt/jwt/v5"
+import (
+	"github.com/golang-jwt/jwt/v5"
+	"strconv"
+	"time"
+	"tspo_server/model"
+)
 
 type Credentials struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
 }
 
-type Claims struct {
+type RegisterRequest struct {
 	Username string `json:"username"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type Claims struct {
+	Username  string `json:"username"`
+	Role      Role   `json:"role,omitempty"`
+	SessionID string `json:"sid,omitempty"`
+	// Заполняются только при аутентификации по API-ключу и в токен не попадают
+	Scopes   []Permission `json:"-"`
+	APIKeyID int64        `json:"-"`
 	jwt.RegisteredClaims
 }
 
+// UserID возвращает идентификатор пользователя из поля sub
+func (c *Claims) UserID() (int64, error) {
+	return strconv.ParseInt(c.Subject, 10, 64)
+}
+
+// Can проверяет разрешение с учётом роли и, для API-ключей, областей действия ключа
+func (c *Claims) Can(perm Permission) bool {
+	if !c.Role.Can(perm) {
+		return false
+	}
+	if c.APIKeyID == 0 {
+		return true
+	}
+	for _, scope := range c.Scopes {
+		if scope == perm {
+			return true
+		}
+	}
+	return false
+}
+
 type TokenResponse struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
@@ -22,3 +59,73 @@ type TokenResponse struct {
 type RefreshRequest struct {
 	RefreshToken string `json:"refresh_token"`
 }
+
+type SessionInfo struct {
+	model.Session
+	Current bool `json:"current"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+type CreateAPIKeyResponse struct {
+	APIKey model.APIKey `json:"api_key"`
+	Key    string       `json:"key"`
+}
+
+type UnlockRequest struct {
+	Username string `json:"username,omitempty"`
+	IP       string `json:"ip,omitempty"`
+}
+
+type TwoFactorSetupResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type TwoFactorVerifyRequest struct {
+	Code string `json:"code"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// TwoFactorChallengeResponse возвращается Login вместо TokenResponse,
+// если у пользователя включена 2FA
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
+	ExpiresIn         int64  `json:"expires_in"`
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code,omitempty"`
+	RecoveryCode   string `json:"recovery_code,omitempty"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type PasswordResetRequest struct {
+	Username string `json:"username"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email"`
+}
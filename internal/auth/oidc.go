@@ -0,0 +1,295 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+type OIDCConfig struct {
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string // адрес GET /auth/oidc/callback, зарегистрированный у IdP
+	Scopes       []string
+	GroupsClaim  string          // claim ID-токена со списком групп, по умолчанию groups
+	RoleMapping  map[string]Role // группа IdP -> роль; из нескольких ролей берётся старшая
+}
+
+// OIDCIdentity - проверенные данные пользователя из ID-токена
+type OIDCIdentity struct {
+	Issuer            string
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	PreferredUsername string
+	Groups            []string
+}
+
+type oidcMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// OIDCProvider реализует сторону клиента OpenID Connect: discovery,
+// authorization code + PKCE (S256) и проверку ID-токенов по JWKS провайдера.
+type OIDCProvider struct {
+	cfg      OIDCConfig
+	client   *http.Client
+	metadata oidcMetadata
+
+	mu          sync.Mutex
+	keys        map[string]interface{}
+	keysFetched time.Time
+}
+
+// Ключи IdP перечитываются при встрече неизвестного kid, но не чаще этого интервала
+const oidcKeysRefreshInterval = time.Minute
+
+// NewOIDCProvider выполняет discovery и проверяет, что issuer в метаданных
+// совпадает с настроенным (OpenID Connect Discovery 1.0, раздел 4.3)
+func NewOIDCProvider(ctx context.Context, cfg OIDCConfig) (*OIDCProvider, error) {
+	if cfg.IssuerURL == "" || cfg.ClientID == "" || cfg.RedirectURL == "" {
+		return nil, errors.New("oidc: issuer URL, client ID and redirect URL are required")
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "profile", "email"}
+	}
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = "groups"
+	}
+
+	p := &OIDCProvider{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+
+	discoveryURL := strings.TrimSuffix(cfg.IssuerURL, "/") + "/.well-known/openid-configuration"
+	if err := p.getJSON(ctx, discoveryURL, &p.metadata); err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	if p.metadata.Issuer != cfg.IssuerURL {
+		return nil, fmt.Errorf("oidc discovery: issuer %q does not match configured %q", p.metadata.Issuer, cfg.IssuerURL)
+	}
+	if p.metadata.AuthorizationEndpoint == "" || p.metadata.TokenEndpoint == "" || p.metadata.JWKSURI == "" {
+		return nil, errors.New("oidc discovery: provider metadata is incomplete")
+	}
+	return p, nil
+}
+
+// AuthCodeURL формирует адрес перенаправления на страницу входа IdP
+func (p *OIDCProvider) AuthCodeURL(state, nonce, codeVerifier string) string {
+	values := url.Values{}
+	values.Set("response_type", "code")
+	values.Set("client_id", p.cfg.ClientID)
+	values.Set("redirect_uri", p.cfg.RedirectURL)
+	values.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	values.Set("state", state)
+	values.Set("nonce", nonce)
+	values.Set("code_challenge", pkceChallenge(codeVerifier))
+	values.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(p.metadata.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return p.metadata.AuthorizationEndpoint + sep + values.Encode()
+}
+
+// Exchange обменивает код авторизации на токены и возвращает ID-токен
+func (p *OIDCProvider) Exchange(ctx context.Context, code, codeVerifier string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.cfg.RedirectURL)
+	form.Set("code_verifier", codeVerifier)
+	form.Set("client_id", p.cfg.ClientID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint returned %d: %s", resp.StatusCode, body)
+	}
+
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err = json.Unmarshal(body, &tokens); err != nil {
+		return "", err
+	}
+	if tokens.IDToken == "" {
+		return "", errors.New("token response does not contain id_token")
+	}
+	return tokens.IDToken, nil
+}
+
+// VerifyIDToken проверяет подпись, iss, aud, azp, exp, iat и nonce ID-токена
+func (p *OIDCProvider) VerifyIDToken(ctx context.Context, rawToken, nonce string) (*OIDCIdentity, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(rawToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512", "EdDSA"}),
+		jwt.WithIssuer(p.metadata.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if aud, _ := claims.GetAudience(); len(aud) > 1 {
+		if azp, _ := claims["azp"].(string); azp != p.cfg.ClientID {
+			return nil, errors.New("id token azp does not match client")
+		}
+	}
+	if tokenNonce, _ := claims["nonce"].(string); tokenNonce == "" || tokenNonce != nonce {
+		return nil, errors.New("id token nonce mismatch")
+	}
+
+	subject, _ := claims.GetSubject()
+	if subject == "" {
+		return nil, errors.New("id token has no subject")
+	}
+
+	identity := &OIDCIdentity{
+		Issuer:  p.metadata.Issuer,
+		Subject: subject,
+	}
+	identity.Email, _ = claims["email"].(string)
+	identity.EmailVerified, _ = claims["email_verified"].(bool)
+	identity.PreferredUsername, _ = claims["preferred_username"].(string)
+
+	switch groups := claims[p.cfg.GroupsClaim].(type) {
+	case []interface{}:
+		for _, group := range groups {
+			if name, ok := group.(string); ok {
+				identity.Groups = append(identity.Groups, name)
+			}
+		}
+	case string:
+		identity.Groups = strings.Fields(groups)
+	}
+	return identity, nil
+}
+
+// Role выбирает старшую роль из сопоставленных группам пользователя
+func (p *OIDCProvider) Role(groups []string) Role {
+	ranks := map[Role]int{RoleReader: 0, RoleLibrarian: 1, RoleAdmin: 2}
+	role := RoleReader
+	for _, group := range groups {
+		if mapped, ok := p.cfg.RoleMapping[group]; ok && ranks[mapped] > ranks[role] {
+			role = mapped
+		}
+	}
+	return role
+}
+
+func (p *OIDCProvider) key(ctx context.Context, kid string) (interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(p.keysFetched) < oidcKeysRefreshInterval {
+		return nil, fmt.Errorf("unknown signing key: %q", kid)
+	}
+
+	var set JWKSet
+	if err := p.getJSON(ctx, p.metadata.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("fetch jwks: %w", err)
+	}
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		if key, err := jwk.PublicKey(); err == nil {
+			keys[jwk.Kid] = key
+		}
+	}
+	p.keys = keys
+	p.keysFetched = time.Now()
+
+	key, ok := p.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key: %q", kid)
+	}
+	return key, nil
+}
+
+func (p *OIDCProvider) getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+// pkceChallenge вычисляет code_challenge по методу S256 (RFC 7636)
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// ParseRoleMapping разбирает пары "группа=роль"
+func ParseRoleMapping(pairs []string) (map[string]Role, error) {
+	mapping := make(map[string]Role, len(pairs))
+	for _, pair := range pairs {
+		group, roleName, ok := strings.Cut(pair, "=")
+		if !ok || group == "" {
+			return nil, fmt.Errorf("invalid role mapping %q, expected group=role", pair)
+		}
+		role, err := ParseRole(roleName)
+		if err != nil {
+			return nil, err
+		}
+		mapping[group] = role
+	}
+	return mapping, nil
+}
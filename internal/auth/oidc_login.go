@@ -0,0 +1,291 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	apperrors "tspo_server/internal/errors"
+	"tspo_server/model"
+)
+
+// IdentityStore хранит связи пользователей с внешними IdP
+type IdentityStore interface {
+	GetIdentity(ctx context.Context, issuer, subject string) (*model.UserIdentity, error)
+	// CreateUserWithIdentity атомарно создаёт пользователя и связь с ним. ErrUserExists -
+	// имя или email заняты, ErrConflict - связь для (iss, sub) уже создана параллельным входом.
+	CreateUserWithIdentity(ctx context.Context, user *model.User, identity *model.UserIdentity) error
+}
+
+const (
+	oidcFlowCookie = "oidc_flow"
+	oidcFlowTTL    = 10 * time.Minute
+)
+
+// oidcFlowClaims - состояние незавершённого входа. Хранится в подписанной
+// cookie, поэтому серверу не нужно хранилище для state, nonce и code_verifier.
+type oidcFlowClaims struct {
+	State        string `json:"state"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"cv"`
+	jwt.RegisteredClaims
+}
+
+// OIDCLogin перенаправляет пользователя на страницу входа IdP
+func (m *JWTMiddleware) OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	if m.oidc == nil {
+		http.Error(w, "OIDC login is not configured", http.StatusNotFound)
+		return
+	}
+
+	now := time.Now()
+	flow := oidcFlowClaims{
+		State:        randomHex(16),
+		Nonce:        randomHex(16),
+		CodeVerifier: randomHex(32),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    m.tokens.Issuer,
+			Audience:  jwt.ClaimStrings{m.oidcFlowAudience()},
+			ExpiresAt: jwt.NewNumericDate(now.Add(oidcFlowTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+	cookie, err := jwt.NewWithClaims(jwt.SigningMethodHS256, flow).SignedString(m.refreshSecret)
+	if err != nil {
+		http.Error(w, "Error starting OIDC login", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcFlowCookie,
+		Value:    cookie,
+		Path:     "/auth/oidc",
+		MaxAge:   int(oidcFlowTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, m.oidc.AuthCodeURL(flow.State, flow.Nonce, flow.CodeVerifier), http.StatusFound)
+}
+
+// OIDCCallback завершает вход: проверяет state, обменивает код на ID-токен,
+// создаёт или обновляет пользователя и выдаёт обычную пару токенов.
+// Второй фактор здесь не запрашивается - за него отвечает IdP.
+func (m *JWTMiddleware) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if m.oidc == nil {
+		http.Error(w, "OIDC login is not configured", http.StatusNotFound)
+		return
+	}
+
+	// cookie одноразовая
+	http.SetCookie(w, &http.Cookie{Name: oidcFlowCookie, Path: "/auth/oidc", MaxAge: -1, HttpOnly: true})
+
+	query := r.URL.Query()
+	if idpErr := query.Get("error"); idpErr != "" {
+		m.logger.Warn("OIDC provider returned error", "error", idpErr, "description", query.Get("error_description"))
+		http.Error(w, "Login was rejected by identity provider", http.StatusUnauthorized)
+		return
+	}
+
+	flow, err := m.parseOIDCFlow(r)
+	if err != nil || subtle.ConstantTimeCompare([]byte(flow.State), []byte(query.Get("state"))) != 1 {
+		http.Error(w, "Invalid or expired login state", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	rawIDToken, err := m.oidc.Exchange(ctx, query.Get("code"), flow.CodeVerifier)
+	if err != nil {
+		m.logger.Error("OIDC code exchange failed", "error", err)
+		http.Error(w, "Error completing OIDC login", http.StatusBadGateway)
+		return
+	}
+
+	identity, err := m.oidc.VerifyIDToken(ctx, rawIDToken, flow.Nonce)
+	if err != nil {
+		m.logger.Warn("invalid OIDC ID token", "error", err)
+		http.Error(w, "Invalid ID token", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := m.provisionOIDCUser(ctx, identity)
+	if err != nil {
+		m.logger.Error("failed to provision OIDC user", "error", err, "subject", identity.Subject)
+		http.Error(w, "Error completing OIDC login", http.StatusInternalServerError)
+		return
+	}
+	if user.Status == model.UserStatusDeleted {
+		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		return
+	}
+	if apiErr := checkAccountStatus(user); apiErr != nil {
+		writeJSONError(w, apiErr)
+		return
+	}
+
+	tokens, err := m.generateTokenPair(r, user, "")
+	if err != nil {
+		http.Error(w, "Error generating tokens", http.StatusInternalServerError)
+		return
+	}
+
+	m.logger.Info("OIDC login", "user", user.Username, "issuer", identity.Issuer, "role", user.Role)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// provisionOIDCUser находит пользователя по (iss, sub) или создаёт его при первом
+// входе. Роль при каждом входе синхронизируется с группами IdP.
+func (m *JWTMiddleware) provisionOIDCUser(ctx context.Context, identity *OIDCIdentity) (*model.User, error) {
+	role := string(m.oidc.Role(identity.Groups))
+
+	link, err := m.identities.GetIdentity(ctx, identity.Issuer, identity.Subject)
+	if err == nil {
+		return m.linkedOIDCUser(ctx, link, role)
+	}
+	if !errors.Is(err, apperrors.ErrNotFound) {
+		return nil, err
+	}
+
+	// Пароля у таких пользователей нет: пустой хэш никогда не проходит проверку в Login
+	user := &model.User{
+		Username: oidcUsername(identity),
+		Role:     role,
+		Status:   model.UserStatusActive,
+	}
+	if identity.EmailVerified {
+		now := time.Now()
+		user.Email = identity.Email
+		user.EmailVerifiedAt = &now
+	}
+	link = &model.UserIdentity{Issuer: identity.Issuer, Subject: identity.Subject}
+
+	err = m.identities.CreateUserWithIdentity(ctx, user, link)
+	if errors.Is(err, apperrors.ErrUserExists) {
+		// Имя или email заняты локальной учётной записью. Автоматически связывать
+		// их нельзя, поэтому заводим отдельного пользователя с уникальным именем.
+		user.Username = fmt.Sprintf("%s-%s", user.Username, hashToken(identity.Issuer + identity.Subject)[:8])
+		user.Email = ""
+		user.EmailVerifiedAt = nil
+		err = m.identities.CreateUserWithIdentity(ctx, user, link)
+	}
+	if errors.Is(err, apperrors.ErrConflict) {
+		// Первый вход с тем же sub параллельно завершился раньше: его транзакция
+		// уже создала пользователя, а наша откатилась целиком
+		if link, err = m.identities.GetIdentity(ctx, identity.Issuer, identity.Subject); err != nil {
+			return nil, err
+		}
+		return m.linkedOIDCUser(ctx, link, role)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	m.logger.Info("OIDC user provisioned", "user", user.Username, "issuer", identity.Issuer, "subject", identity.Subject)
+	return user, nil
+}
+
+// linkedOIDCUser возвращает пользователя, связанного с внешней учётной записью,
+// и синхронизирует его роль с группами IdP
+func (m *JWTMiddleware) linkedOIDCUser(ctx context.Context, link *model.UserIdentity, role string) (*model.User, error) {
+	user, err := m.userStore.GetUserByID(ctx, link.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Role != role {
+		if err = m.userStore.UpdateUserRole(ctx, user.ID, role); err != nil {
+			return nil, err
+		}
+		user.Role = role
+	}
+	return user, nil
+}
+
+func oidcUsername(identity *OIDCIdentity) string {
+	switch {
+	case identity.PreferredUsername != "":
+		return identity.PreferredUsername
+	case identity.Email != "":
+		name, _, _ := strings.Cut(identity.Email, "@")
+		return name
+	}
+	return "oidc-" + hashToken(identity.Issuer + identity.Subject)[:12]
+}
+
+func (m *JWTMiddleware) oidcFlowAudience() string {
+	return m.tokens.Audience + ":oidc"
+}
+
+func (m *JWTMiddleware) parseOIDCFlow(r *http.Request) (*oidcFlowClaims, error) {
+	cookie, err := r.Cookie(oidcFlowCookie)
+	if err != nil {
+		return nil, err
+	}
+
+	flow := &oidcFlowClaims{}
+	_, err = jwt.ParseWithClaims(cookie.Value, flow, func(token *jwt.Token) (interface{}, error) {
+		return m.refreshSecret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(m.tokens.Issuer),
+		jwt.WithAudience(m.oidcFlowAudience()),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if flow.State == "" || flow.Nonce == "" || flow.CodeVerifier == "" {
+		return nil, errors.New("incomplete OIDC flow state")
+	}
+	return flow, nil
+}
+
+// MemoryIdentityStore создаёт пользователей в MemoryUserStore
+type MemoryIdentityStore struct {
+	identities map[string]model.UserIdentity // issuer + " " + subject -> identity
+	users      *MemoryUserStore
+	mu         sync.Mutex
+}
+
+func NewMemoryIdentityStore(users *MemoryUserStore) *MemoryIdentityStore {
+	return &MemoryIdentityStore{
+		identities: make(map[string]model.UserIdentity),
+		users:      users,
+	}
+}
+
+func (s *MemoryIdentityStore) GetIdentity(ctx context.Context, issuer, subject string) (*model.UserIdentity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	identity, exists := s.identities[issuer+" "+subject]
+	if !exists {
+		return nil, apperrors.ErrNotFound
+	}
+	return &identity, nil
+}
+
+func (s *MemoryIdentityStore) CreateUserWithIdentity(ctx context.Context, user *model.User, identity *model.UserIdentity) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := identity.Issuer + " " + identity.Subject
+	if _, exists := s.identities[key]; exists {
+		return apperrors.ErrConflict
+	}
+	if err := s.users.CreateUser(ctx, user); err != nil {
+		return err
+	}
+	identity.UserID = user.ID
+	identity.CreatedAt = time.Now()
+	s.identities[key] = *identity
+	return nil
+}
@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+)
+
+const (
+	AlgorithmBcrypt   = "bcrypt"
+	AlgorithmArgon2id = "argon2id"
+)
+
+var ErrUnknownHashFormat = errors.New("unknown password hash format")
+
+type PasswordHashConfig struct {
+	Algorithm      string // bcrypt, argon2id
+	BcryptCost     int
+	Argon2Time     uint32
+	Argon2MemoryKB uint32
+	Argon2Threads  uint8
+}
+
+// PasswordHasher хэширует пароли выбранным алгоритмом и проверяет хэши,
+// созданные любым из поддерживаемых алгоритмов, чтобы смена настроек
+// не ломала вход для уже зарегистрированных пользователей.
+type PasswordHasher struct {
+	cfg PasswordHashConfig
+}
+
+type argon2Params struct {
+	time    uint32
+	memory  uint32
+	threads uint8
+	salt    []byte
+	key     []byte
+}
+
+const (
+	argon2SaltLen = 16
+	argon2KeyLen  = 32
+)
+
+func NewPasswordHasher(cfg PasswordHashConfig) (*PasswordHasher, error) {
+	switch cfg.Algorithm {
+	case AlgorithmBcrypt:
+		if cfg.BcryptCost < bcrypt.MinCost || cfg.BcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	case AlgorithmArgon2id:
+		if cfg.Argon2Time == 0 || cfg.Argon2MemoryKB == 0 || cfg.Argon2Threads == 0 {
+			return nil, errors.New("argon2id time, memory and threads must be positive")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported password hash algorithm: %q", cfg.Algorithm)
+	}
+	return &PasswordHasher{cfg: cfg}, nil
+}
+
+func (h *PasswordHasher) Hash(password string) (string, error) {
+	if h.cfg.Algorithm == AlgorithmArgon2id {
+		salt := make([]byte, argon2SaltLen)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		key := argon2.IDKey([]byte(password), salt, h.cfg.Argon2Time, h.cfg.Argon2MemoryKB, h.cfg.Argon2Threads, argon2KeyLen)
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+			argon2.Version, h.cfg.Argon2MemoryKB, h.cfg.Argon2Time, h.cfg.Argon2Threads,
+			base64.RawStdEncoding.EncodeToString(salt),
+			base64.RawStdEncoding.EncodeToString(key)), nil
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.cfg.BcryptCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// Verify сравнивает пароль с хэшем за постоянное время. needsRehash = true,
+// если хэш создан другим алгоритмом или с другими параметрами стоимости.
+func (h *PasswordHasher) Verify(encoded, password string) (ok bool, needsRehash bool, err error) {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		p, err := parseArgon2Hash(encoded)
+		if err != nil {
+			return false, false, err
+		}
+		key := argon2.IDKey([]byte(password), p.salt, p.time, p.memory, p.threads, uint32(len(p.key)))
+		if subtle.ConstantTimeCompare(key, p.key) != 1 {
+			return false, false, nil
+		}
+		needsRehash = h.cfg.Algorithm != AlgorithmArgon2id ||
+			p.time != h.cfg.Argon2Time || p.memory != h.cfg.Argon2MemoryKB || p.threads != h.cfg.Argon2Threads
+		return true, needsRehash, nil
+
+	case strings.HasPrefix(encoded, "$2a$"), strings.HasPrefix(encoded, "$2b$"), strings.HasPrefix(encoded, "$2y$"):
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, false, nil
+		}
+		if err != nil {
+			return false, false, err
+		}
+		cost, err := bcrypt.Cost([]byte(encoded))
+		if err != nil {
+			return false, false, err
+		}
+		needsRehash = h.cfg.Algorithm != AlgorithmBcrypt || cost != h.cfg.BcryptCost
+		return true, needsRehash, nil
+	}
+
+	return false, false, ErrUnknownHashFormat
+}
+
+func parseArgon2Hash(encoded string) (*argon2Params, error) {
+	// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return nil, ErrUnknownHashFormat
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, ErrUnknownHashFormat
+	}
+
+	p := &argon2Params{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.time, &p.threads); err != nil {
+		return nil, ErrUnknownHashFormat
+	}
+
+	var err error
+	if p.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, ErrUnknownHashFormat
+	}
+	if p.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return nil, ErrUnknownHashFormat
+	}
+	return p, nil
+}
@@ -0,0 +1,299 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	apperrors "tspo_server/internal/errors"
+	"tspo_server/internal/notify"
+	"tspo_server/model"
+)
+
+// PasswordResetStore хранит токены сброса пароля по SHA-256 хэшу
+type PasswordResetStore interface {
+	// CreateResetToken сохраняет токен и делает недействительными прежние токены пользователя
+	CreateResetToken(ctx context.Context, token *model.PasswordResetToken) error
+	GetResetToken(ctx context.Context, tokenHash string) (*model.PasswordResetToken, error)
+	// ConsumeResetToken атомарно помечает токен использованным. ErrNotFound, если
+	// токена нет, он уже использован или истёк.
+	ConsumeResetToken(ctx context.Context, tokenHash string) error
+}
+
+// ChangePassword меняет пароль текущего пользователя по текущему паролю
+// и завершает все остальные его сессии
+func (m *JWTMiddleware) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	claims, userID, ok := m.requireInteractiveUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	user, err := m.userStore.GetUserByID(ctx, userID)
+	if errors.Is(err, apperrors.ErrNotFound) {
+		http.Error(w, "invalid token claims", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error changing password", http.StatusInternalServerError)
+		return
+	}
+
+	if user.PasswordHash == "" {
+		http.Error(w, "Password login is not enabled for this account", http.StatusForbidden)
+		return
+	}
+
+	ok, _, err = m.hasher.Verify(user.PasswordHash, req.CurrentPassword)
+	if err != nil {
+		m.logger.Error("failed to verify password hash", "error", err, "user", user.Username)
+		http.Error(w, "Error changing password", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		// Неверный текущий пароль учитывается как неудачный вход, чтобы украденный
+		// access-токен нельзя было использовать для подбора пароля
+		delay, err := m.limiter.RecordFailure(ctx, user.Username, clientIP(r))
+		if err != nil {
+			m.logger.Error("failed to record login failure", "error", err)
+		}
+		sleepContext(ctx, delay)
+		http.Error(w, "Current password is incorrect", http.StatusForbidden)
+		return
+	}
+
+	if !m.setPassword(w, r, user, req.NewPassword) {
+		return
+	}
+
+	if err = m.revokeOtherSessions(ctx, userID, claims.SessionID); err != nil {
+		m.logger.Error("failed to revoke other sessions", "error", err, "user", user.Username)
+		http.Error(w, "Error changing password", http.StatusInternalServerError)
+		return
+	}
+
+	m.logger.Info("password changed", "user", user.Username)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Password changed successfully"})
+}
+
+// RequestPasswordReset выпускает токен сброса и отправляет его через Notifier.
+// Ответ одинаков независимо от существования пользователя.
+func (m *JWTMiddleware) RequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var req PasswordResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Username) == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := m.sendPasswordReset(r.Context(), req.Username); err != nil {
+		m.logger.Error("failed to send password reset", "error", err, "user", req.Username)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "If the account exists, password reset instructions have been sent",
+	})
+}
+
+func (m *JWTMiddleware) sendPasswordReset(ctx context.Context, username string) error {
+	user, err := m.userStore.GetUserByUsername(ctx, username)
+	if errors.Is(err, apperrors.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// Пользователи OIDC входят только через IdP
+	if user.Status != model.UserStatusActive || user.PasswordHash == "" {
+		return nil
+	}
+	if user.Email == "" {
+		return fmt.Errorf("user has no email address")
+	}
+
+	rawToken := randomHex(32)
+	token := &model.PasswordResetToken{
+		TokenHash: hashToken(rawToken),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(m.resetTokenTTL),
+	}
+	if err = m.resets.CreateResetToken(ctx, token); err != nil {
+		return err
+	}
+
+	return m.notifier.Notify(ctx, notify.Message{
+		To:      user.Email,
+		Subject: "Password reset",
+		Body: fmt.Sprintf("Use this token to reset your password: %s\nThe token expires at %s.",
+			rawToken, token.ExpiresAt.Format(time.RFC3339)),
+	})
+}
+
+// ResetPassword устанавливает новый пароль по токену сброса и завершает все сессии пользователя
+func (m *JWTMiddleware) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	tokenHash := hashToken(req.Token)
+
+	token, err := m.resets.GetResetToken(ctx, tokenHash)
+	if errors.Is(err, apperrors.ErrNotFound) || (err == nil && (token.UsedAt != nil || time.Now().After(token.ExpiresAt))) {
+		http.Error(w, "Invalid or expired reset token", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error resetting password", http.StatusInternalServerError)
+		return
+	}
+
+	user, err := m.userStore.GetUserByID(ctx, token.UserID)
+	if errors.Is(err, apperrors.ErrNotFound) || (err == nil && user.Status != model.UserStatusActive) {
+		http.Error(w, "Invalid or expired reset token", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error resetting password", http.StatusInternalServerError)
+		return
+	}
+
+	// Пароль проверяется политикой до использования токена, чтобы отклонённый
+	// пароль не сжигал токен
+	if violations := m.passwordPolicy.Validate(user.Username, req.NewPassword); len(violations) > 0 {
+		writeJSONError(w, apperrors.NewValidationAPIError(http.StatusUnprocessableEntity,
+			"Password is invalid", violations))
+		return
+	}
+
+	err = m.resets.ConsumeResetToken(ctx, tokenHash)
+	if errors.Is(err, apperrors.ErrNotFound) {
+		http.Error(w, "Invalid or expired reset token", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error resetting password", http.StatusInternalServerError)
+		return
+	}
+
+	if !m.setPassword(w, r, user, req.NewPassword) {
+		return
+	}
+
+	if err = m.sessions.RevokeUserSessions(ctx, user.ID); err != nil {
+		m.logger.Error("failed to revoke user sessions", "error", err, "user", user.Username)
+	}
+	if err = m.limiter.Reset(ctx, userAttemptKey(user.Username)); err != nil {
+		m.logger.Warn("failed to reset login failures", "error", err, "user", user.Username)
+	}
+
+	m.logger.Info("password reset", "user", user.Username)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Password has been reset"})
+}
+
+// setPassword проверяет пароль политикой, хэширует и сохраняет его.
+// При ошибке пишет ответ и возвращает false.
+func (m *JWTMiddleware) setPassword(w http.ResponseWriter, r *http.Request, user *model.User, password string) bool {
+	if violations := m.passwordPolicy.Validate(user.Username, password); len(violations) > 0 {
+		writeJSONError(w, apperrors.NewValidationAPIError(http.StatusUnprocessableEntity,
+			"Password is invalid", violations))
+		return false
+	}
+
+	hash, err := m.hasher.Hash(password)
+	if err == nil {
+		err = m.userStore.UpdatePasswordHash(r.Context(), user.ID, hash)
+	}
+	if err != nil {
+		m.logger.Error("failed to update password", "error", err, "user", user.Username)
+		http.Error(w, "Error updating password", http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+func (m *JWTMiddleware) revokeOtherSessions(ctx context.Context, userID int64, currentSessionID string) error {
+	sessions, err := m.sessions.ListUserSessions(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		if session.ID == currentSessionID {
+			continue
+		}
+		if err = m.sessions.RevokeSession(ctx, session.ID); err != nil && !errors.Is(err, apperrors.ErrNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+type MemoryPasswordResetStore struct {
+	tokens map[string]model.PasswordResetToken
+	mu     sync.Mutex
+}
+
+func NewMemoryPasswordResetStore() *MemoryPasswordResetStore {
+	return &MemoryPasswordResetStore{
+		tokens: make(map[string]model.PasswordResetToken),
+	}
+}
+
+func (s *MemoryPasswordResetStore) CreateResetToken(ctx context.Context, token *model.PasswordResetToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for hash, existing := range s.tokens {
+		if existing.UserID == token.UserID && existing.UsedAt == nil {
+			existing.UsedAt = &now
+			s.tokens[hash] = existing
+		}
+	}
+	token.CreatedAt = now
+	s.tokens[token.TokenHash] = *token
+	return nil
+}
+
+func (s *MemoryPasswordResetStore) GetResetToken(ctx context.Context, tokenHash string) (*model.PasswordResetToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, exists := s.tokens[tokenHash]
+	if !exists {
+		return nil, apperrors.ErrNotFound
+	}
+	return &token, nil
+}
+
+func (s *MemoryPasswordResetStore) ConsumeResetToken(ctx context.Context, tokenHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	token, exists := s.tokens[tokenHash]
+	if !exists || token.UsedAt != nil || now.After(token.ExpiresAt) {
+		return apperrors.ErrNotFound
+	}
+	token.UsedAt = &now
+	s.tokens[tokenHash] = token
+	return nil
+}
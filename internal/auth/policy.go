@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+	apperrors "tspo_server/internal/errors"
+	"unicode"
+)
+
+// Пароли, которые отклоняются всегда, вне зависимости от настроек
+var defaultBannedPasswords = []string{
+	"password", "password1", "password123", "12345678", "123456789", "1234567890",
+	"qwerty123", "qwertyuiop", "iloveyou", "admin123", "letmein1", "welcome1",
+}
+
+type PasswordPolicy struct {
+	MinLength     int
+	MaxLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	Banned        []string
+}
+
+// Validate проверяет учётные данные при регистрации и возвращает список
+// нарушений по полям. Пустой список означает, что пароль допустим.
+func (p PasswordPolicy) Validate(username, password string) []apperrors.FieldError {
+	var violations []apperrors.FieldError
+
+	if strings.TrimSpace(username) == "" {
+		violations = append(violations, apperrors.FieldError{
+			Field: "username", Code: "required", Message: "username is required",
+		})
+	}
+
+	length := len([]rune(password))
+	if length < p.MinLength {
+		violations = append(violations, apperrors.FieldError{
+			Field: "password", Code: "too_short",
+			Message: fmt.Sprintf("password must be at least %d characters long", p.MinLength),
+		})
+	}
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		violations = append(violations, apperrors.FieldError{
+			Field: "password", Code: "too_long",
+			Message: fmt.Sprintf("password must be at most %d bytes long", p.MaxLength),
+		})
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, c := range password {
+		switch {
+		case unicode.IsUpper(c):
+			hasUpper = true
+		case unicode.IsLower(c):
+			hasLower = true
+		case unicode.IsDigit(c):
+			hasDigit = true
+		case unicode.IsPunct(c) || unicode.IsSymbol(c):
+			hasSymbol = true
+		}
+	}
+	if p.RequireUpper && !hasUpper {
+		violations = append(violations, apperrors.FieldError{
+			Field: "password", Code: "missing_upper", Message: "password must contain an uppercase letter",
+		})
+	}
+	if p.RequireLower && !hasLower {
+		violations = append(violations, apperrors.FieldError{
+			Field: "password", Code: "missing_lower", Message: "password must contain a lowercase letter",
+		})
+	}
+	if p.RequireDigit && !hasDigit {
+		violations = append(violations, apperrors.FieldError{
+			Field: "password", Code: "missing_digit", Message: "password must contain a digit",
+		})
+	}
+	if p.RequireSymbol && !hasSymbol {
+		violations = append(violations, apperrors.FieldError{
+			Field: "password", Code: "missing_symbol", Message: "password must contain a special character",
+		})
+	}
+
+	if p.isBanned(username, password) {
+		violations = append(violations, apperrors.FieldError{
+			Field: "password", Code: "banned", Message: "password is too common or matches the username",
+		})
+	}
+
+	return violations
+}
+
+func (p PasswordPolicy) isBanned(username, password string) bool {
+	lower := strings.ToLower(password)
+	if username != "" && lower == strings.ToLower(username) {
+		return true
+	}
+	for _, list := range [][]string{defaultBannedPasswords, p.Banned} {
+		for _, banned := range list {
+			if lower == strings.ToLower(banned) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// RevocationStore - постоянное хранилище отозванных токенов, ключ - jti
+type RevocationStore interface {
+	Revoke(ctx context.Context, jti string, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+	PurgeExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+// RevocationList кэширует отозванные jti в памяти поверх RevocationStore.
+// Кэшируются только положительные ответы: токен, отозванный на другой реплике,
+// всё равно будет найден в хранилище.
+type RevocationList struct {
+	store  RevocationStore
+	cache  map[string]time.Time // jti -> exp токена
+	mu     sync.RWMutex
+	logger *slog.Logger
+}
+
+func NewRevocationList(store RevocationStore, logger *slog.Logger) *RevocationList {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &RevocationList{
+		store:  store,
+		cache:  make(map[string]time.Time),
+		logger: logger,
+	}
+}
+
+// Revoke отзывает токен до момента его собственного истечения срока действия
+func (l *RevocationList) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	if err := l.store.Revoke(ctx, jti, expiresAt); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	l.cache[jti] = expiresAt
+	l.mu.Unlock()
+	return nil
+}
+
+func (l *RevocationList) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	l.mu.RLock()
+	expiry, cached := l.cache[jti]
+	l.mu.RUnlock()
+
+	if cached {
+		// Просроченный токен не пройдёт проверку exp, запись удалит janitor
+		return time.Now().Before(expiry), nil
+	}
+
+	revoked, err := l.store.IsRevoked(ctx, jti)
+	if err != nil {
+		return false, err
+	}
+	return revoked, nil
+}
+
+// RunJanitor периодически удаляет просроченные записи из кэша и хранилища.
+// Блокируется до отмены ctx, поэтому запускается в отдельной горутине.
+func (l *RevocationList) RunJanitor(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			l.prune(ctx)
+		}
+	}
+}
+
+func (l *RevocationList) prune(ctx context.Context) {
+	now := time.Now()
+
+	l.mu.Lock()
+	for jti, expiry := range l.cache {
+		if now.After(expiry) {
+			delete(l.cache, jti)
+		}
+	}
+	l.mu.Unlock()
+
+	purged, err := l.store.PurgeExpired(ctx, now)
+	if err != nil {
+		l.logger.Error("failed to purge expired revoked tokens", "error", err)
+		return
+	}
+	if purged > 0 {
+		l.logger.Debug("purged expired revoked tokens", "count", purged)
+	}
+}
+
+type MemoryRevocationStore struct {
+	tokens map[string]time.Time
+	mu     sync.RWMutex
+}
+
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{
+		tokens: make(map[string]time.Time),
+	}
+}
+
+func (s *MemoryRevocationStore) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[jti] = expiresAt
+	return nil
+}
+
+func (s *MemoryRevocationStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	expiry, exists := s.tokens[jti]
+	return exists && time.Now().Before(expiry), nil
+}
+
+func (s *MemoryRevocationStore) PurgeExpired(ctx context.Context, now time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged int64
+	for jti, expiry := range s.tokens {
+		if now.After(expiry) {
+			delete(s.tokens, jti)
+			purged++
+		}
+	}
+	return purged, nil
+}
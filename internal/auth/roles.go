@@ -0,0 +1,46 @@
+package auth
+
+import "fmt"
+
+type Role string
+
+const (
+	RoleReader    Role = "reader"
+	RoleLibrarian Role = "librarian"
+	RoleAdmin     Role = "admin"
+)
+
+type Permission string
+
+const (
+	PermBooksRead   Permission = "books:read"
+	PermBooksCreate Permission = "books:create"
+	PermBooksUpdate Permission = "books:update"
+	PermBooksDelete Permission = "books:delete"
+	PermBooksPurge  Permission = "books:purge" // безвозвратное удаление, минуя корзину
+	PermUsersManage Permission = "users:manage"
+)
+
+var rolePermissions = map[Role][]Permission{
+	RoleReader:    {PermBooksRead},
+	RoleLibrarian: {PermBooksRead, PermBooksCreate, PermBooksUpdate},
+	RoleAdmin:     {PermBooksRead, PermBooksCreate, PermBooksUpdate, PermBooksDelete, PermBooksPurge, PermUsersManage},
+}
+
+func ParseRole(s string) (Role, error) {
+	role := Role(s)
+	if _, ok := rolePermissions[role]; !ok {
+		return "", fmt.Errorf("unknown role: %q", s)
+	}
+	return role, nil
+}
+
+// Can сообщает, входит ли разрешение в набор прав роли
+func (r Role) Can(perm Permission) bool {
+	for _, p := range rolePermissions[r] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+package auth
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+	"tspo_server/internal/errors"
+	"tspo_server/model"
+)
+
+// SessionStore хранит сессии (семейства refresh-токенов) и выданные refresh-токены.
+// ConsumeRefreshToken должен атомарно помечать токен использованным и
+// возвращать errors.ErrTokenReused при повторном предъявлении.
+type SessionStore interface {
+	CreateSession(ctx context.Context, session *model.Session) error
+	GetSession(ctx context.Context, id string) (*model.Session, error)
+	ListUserSessions(ctx context.Context, userID int64) ([]model.Session, error)
+	TouchSession(ctx context.Context, id, ipAddress, userAgent string, expiresAt time.Time) error
+	RevokeSession(ctx context.Context, id string) error
+	RevokeUserSessions(ctx context.Context, userID int64) error
+	SaveRefreshToken(ctx context.Context, token *model.RefreshToken) error
+	ConsumeRefreshToken(ctx context.Context, id string) (*model.RefreshToken, error)
+}
+
+type MemorySessionStore struct {
+	sessions map[string]model.Session
+	tokens   map[string]model.RefreshToken
+	mu       sync.Mutex
+}
+
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{
+		sessions: make(map[string]model.Session),
+		tokens:   make(map[string]model.RefreshToken),
+	}
+}
+
+func (s *MemorySessionStore) CreateSession(ctx context.Context, session *model.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session.CreatedAt = time.Now()
+	session.LastUsedAt = session.CreatedAt
+	s.sessions[session.ID] = *session
+	return nil
+}
+
+func (s *MemorySessionStore) GetSession(ctx context.Context, id string) (*model.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, exists := s.sessions[id]
+	if !exists {
+		return nil, errors.ErrNotFound
+	}
+	return &session, nil
+}
+
+func (s *MemorySessionStore) ListUserSessions(ctx context.Context, userID int64) ([]model.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var sessions []model.Session
+	for _, session := range s.sessions {
+		if session.UserID == userID && session.RevokedAt == nil && session.ExpiresAt.After(now) {
+			sessions = append(sessions, session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+	})
+	return sessions, nil
+}
+
+func (s *MemorySessionStore) TouchSession(ctx context.Context, id, ipAddress, userAgent string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, exists := s.sessions[id]
+	if !exists {
+		return errors.ErrNotFound
+	}
+	session.IPAddress = ipAddress
+	session.UserAgent = userAgent
+	session.LastUsedAt = time.Now()
+	session.ExpiresAt = expiresAt
+	s.sessions[id] = session
+	return nil
+}
+
+func (s *MemorySessionStore) RevokeSession(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, exists := s.sessions[id]
+	if !exists {
+		return errors.ErrNotFound
+	}
+	if session.RevokedAt == nil {
+		now := time.Now()
+		session.RevokedAt = &now
+		s.sessions[id] = session
+	}
+	return nil
+}
+
+func (s *MemorySessionStore) RevokeUserSessions(ctx context.Context, userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, session := range s.sessions {
+		if session.UserID == userID && session.RevokedAt == nil {
+			session.RevokedAt = &now
+			s.sessions[id] = session
+		}
+	}
+	return nil
+}
+
+func (s *MemorySessionStore) SaveRefreshToken(ctx context.Context, token *model.RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token.CreatedAt = time.Now()
+	s.tokens[token.ID] = *token
+	return nil
+}
+
+func (s *MemorySessionStore) ConsumeRefreshToken(ctx context.Context, id string) (*model.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, exists := s.tokens[id]
+	if !exists {
+		return nil, errors.ErrNotFound
+	}
+	if token.UsedAt != nil {
+		return &token, errors.ErrTokenReused
+	}
+
+	now := time.Now()
+	token.UsedAt = &now
+	s.tokens[id] = token
+	return &token, nil
+}
@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	apperrors "tspo_server/internal/errors"
+)
+
+// ListSessions возвращает активные сессии текущего пользователя.
+// Требует RequireAuth: claims берутся из контекста запроса. API-ключам сессии недоступны.
+func (m *JWTMiddleware) ListSessions(w http.ResponseWriter, r *http.Request) {
+	claims, userID, ok := m.requireInteractiveUser(w, r)
+	if !ok {
+		return
+	}
+
+	sessions, err := m.sessions.ListUserSessions(r.Context(), userID)
+	if err != nil {
+		m.logger.Error("failed to list sessions", "error", err, "user", claims.Username)
+		http.Error(w, "Error listing sessions", http.StatusInternalServerError)
+		return
+	}
+
+	result := make([]SessionInfo, 0, len(sessions))
+	for _, session := range sessions {
+		result = append(result, SessionInfo{
+			Session: session,
+			Current: session.ID == claims.SessionID,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]SessionInfo{"sessions": result})
+}
+
+// RevokeSession завершает одну сессию текущего пользователя по id
+func (m *JWTMiddleware) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	claims, userID, ok := m.requireInteractiveUser(w, r)
+	if !ok {
+		return
+	}
+
+	id := r.PathValue("id")
+	session, err := m.sessions.GetSession(r.Context(), id)
+	if errors.Is(err, apperrors.ErrNotFound) || (err == nil && session.UserID != userID) {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error revoking session", http.StatusInternalServerError)
+		return
+	}
+
+	if err = m.sessions.RevokeSession(r.Context(), id); err != nil {
+		m.logger.Error("failed to revoke session", "error", err, "session_id", id, "user", claims.Username)
+		http.Error(w, "Error revoking session", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LogoutAll завершает все сессии пользователя, включая текущую
+func (m *JWTMiddleware) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	claims, userID, ok := m.requireInteractiveUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := m.sessions.RevokeUserSessions(r.Context(), userID); err != nil {
+		m.logger.Error("failed to revoke user sessions", "error", err, "user", claims.Username)
+		http.Error(w, "Error revoking sessions", http.StatusInternalServerError)
+		return
+	}
+
+	if claims.ID != "" && claims.ExpiresAt != nil {
+		if err := m.revocations.Revoke(r.Context(), claims.ID, claims.ExpiresAt.Time); err != nil {
+			m.logger.Error("failed to revoke token", "error", err, "user", claims.Username)
+		}
+	}
+
+	m.logger.Info("all sessions revoked", "user", claims.Username)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Logged out from all sessions"})
+}
+
+// requireUser достаёт claims из контекста и id пользователя из них
+func (m *JWTMiddleware) requireUser(w http.ResponseWriter, r *http.Request) (*Claims, int64, bool) {
+	claims, ok := ClaimsFromContext(r.Context())
+	if !ok {
+		http.Error(w, "authorization required", http.StatusUnauthorized)
+		return nil, 0, false
+	}
+
+	userID, err := claims.UserID()
+	if err != nil {
+		http.Error(w, "invalid token claims", http.StatusUnauthorized)
+		return nil, 0, false
+	}
+
+	return claims, userID, true
+}
+
+// clientIP возвращает адрес клиента без порта. X-Forwarded-For не учитывается,
+// так как его может подделать любой клиент.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+	"tspo_server/internal/errors"
+	"tspo_server/model"
+)
+
+// UserStore - хранилище пользователей, с которым работает JWTMiddleware.
+// В продакшене используется db.UserRepository, MemoryUserStore подходит для тестов.
+type UserStore interface {
+	CreateUser(ctx context.Context, user *model.User) error
+	GetUserByUsername(ctx context.Context, username string) (*model.User, error)
+	GetUserByID(ctx context.Context, id int64) (*model.User, error)
+	GetUserByEmail(ctx context.Context, email string) (*model.User, error)
+	UpdatePasswordHash(ctx context.Context, id int64, hash string) error
+	UpdateUserRole(ctx context.Context, id int64, role string) error
+	// MarkEmailVerified подтверждает email и активирует учётную запись в статусе pending
+	MarkEmailVerified(ctx context.Context, id int64) error
+}
+
+type MemoryUserStore struct {
+	users  map[string]model.User // username -> user
+	nextID int64
+	mu     sync.RWMutex
+}
+
+func NewMemoryUserStore() *MemoryUserStore {
+	return &MemoryUserStore{
+		users: make(map[string]model.User),
+	}
+}
+
+func (s *MemoryUserStore) CreateUser(ctx context.Context, user *model.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.users[user.Username]; exists {
+		return errors.ErrUserExists
+	}
+	for _, existing := range s.users {
+		if user.Email != "" && strings.EqualFold(existing.Email, user.Email) {
+			return errors.ErrUserExists
+		}
+	}
+
+	s.nextID++
+	user.ID = s.nextID
+	user.CreatedAt = time.Now()
+	s.users[user.Username] = *user
+	return nil
+}
+
+func (s *MemoryUserStore) GetUserByUsername(ctx context.Context, username string) (*model.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, exists := s.users[username]
+	if !exists {
+		return nil, errors.ErrNotFound
+	}
+	return &user, nil
+}
+
+func (s *MemoryUserStore) GetUserByID(ctx context.Context, id int64) (*model.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, user := range s.users {
+		if user.ID == id {
+			return &user, nil
+		}
+	}
+	return nil, errors.ErrNotFound
+}
+
+func (s *MemoryUserStore) GetUserByEmail(ctx context.Context, email string) (*model.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, user := range s.users {
+		if user.Email != "" && strings.EqualFold(user.Email, email) {
+			return &user, nil
+		}
+	}
+	return nil, errors.ErrNotFound
+}
+
+func (s *MemoryUserStore) UpdatePasswordHash(ctx context.Context, id int64, hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for username, user := range s.users {
+		if user.ID == id {
+			user.PasswordHash = hash
+			s.users[username] = user
+			return nil
+		}
+	}
+	return errors.ErrNotFound
+}
+
+func (s *MemoryUserStore) UpdateUserRole(ctx context.Context, id int64, role string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for username, user := range s.users {
+		if user.ID == id {
+			user.Role = role
+			s.users[username] = user
+			return nil
+		}
+	}
+	return errors.ErrNotFound
+}
+
+func (s *MemoryUserStore) MarkEmailVerified(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for username, user := range s.users {
+		if user.ID == id {
+			now := time.Now()
+			user.EmailVerifiedAt = &now
+			if user.Status == model.UserStatusPending {
+				user.Status = model.UserStatusActive
+			}
+			s.users[username] = user
+			return nil
+		}
+	}
+	return errors.ErrNotFound
+}
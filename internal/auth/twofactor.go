@@ -0,0 +1,329 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	apperrors "tspo_server/internal/errors"
+	"tspo_server/model"
+)
+
+const (
+	twoFactorChallengeTTL = 5 * time.Minute
+	recoveryCodeCount     = 10
+)
+
+// twoFactorRoles - роли, которым доступна двухфакторная аутентификация
+var twoFactorRoles = map[Role]bool{
+	RoleLibrarian: true,
+	RoleAdmin:     true,
+}
+
+// SetupTwoFactor генерирует новый секрет TOTP. 2FA включается только после
+// подтверждения кодом в VerifyTwoFactor, до этого вход работает по паролю.
+func (m *JWTMiddleware) SetupTwoFactor(w http.ResponseWriter, r *http.Request) {
+	claims, userID, ok := m.requireInteractiveUser(w, r)
+	if !ok {
+		return
+	}
+	if !twoFactorRoles[claims.Role] {
+		http.Error(w, "Two-factor authentication is available only for librarian and admin accounts", http.StatusForbidden)
+		return
+	}
+
+	secret, err := newTOTPSecret()
+	if err != nil {
+		http.Error(w, "Error setting up two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+
+	err = m.twoFactor.SaveTOTPSecret(r.Context(), userID, secret)
+	if errors.Is(err, apperrors.ErrNotFound) {
+		http.Error(w, "Two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		m.logger.Error("failed to save TOTP secret", "error", err, "user", claims.Username)
+		http.Error(w, "Error setting up two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(TwoFactorSetupResponse{
+		Secret:          secret,
+		ProvisioningURI: totpProvisioningURI(m.tokens.Issuer, claims.Username, secret),
+	})
+}
+
+// VerifyTwoFactor подтверждает настройку первым кодом из приложения, включает 2FA
+// и возвращает коды восстановления. Коды показываются только в этом ответе.
+func (m *JWTMiddleware) VerifyTwoFactor(w http.ResponseWriter, r *http.Request) {
+	claims, userID, ok := m.requireInteractiveUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req TwoFactorVerifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	totp, err := m.twoFactor.GetTOTP(ctx, userID)
+	if errors.Is(err, apperrors.ErrNotFound) {
+		http.Error(w, "Two-factor setup has not been started", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error verifying two-factor code", http.StatusInternalServerError)
+		return
+	}
+	if totp.ConfirmedAt != nil {
+		http.Error(w, "Two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+
+	step, valid := validateTOTP(totp.Secret, strings.TrimSpace(req.Code), time.Now())
+	if !valid {
+		http.Error(w, "Invalid two-factor code", http.StatusBadRequest)
+		return
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		codes[i] = fmt.Sprintf("%s-%s", randomHex(3), randomHex(3))
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+
+	err = m.twoFactor.ConfirmTOTP(ctx, userID, step, hashes)
+	if errors.Is(err, apperrors.ErrNotFound) {
+		http.Error(w, "Two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		m.logger.Error("failed to confirm TOTP", "error", err, "user", claims.Username)
+		http.Error(w, "Error verifying two-factor code", http.StatusInternalServerError)
+		return
+	}
+
+	m.logger.Info("two-factor authentication enabled", "user", claims.Username)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// LoginTwoFactor - второй шаг входа: обменивает challenge-токен и код TOTP
+// (или код восстановления) на пару токенов
+func (m *JWTMiddleware) LoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var req TwoFactorLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	claims, err := m.parseChallengeToken(req.ChallengeToken)
+	if err != nil {
+		http.Error(w, "Invalid challenge token", http.StatusUnauthorized)
+		return
+	}
+	revoked, err := m.revocations.IsRevoked(ctx, claims.ID)
+	if err != nil {
+		http.Error(w, "Error validating credentials", http.StatusInternalServerError)
+		return
+	}
+	if revoked {
+		http.Error(w, "Invalid challenge token", http.StatusUnauthorized)
+		return
+	}
+
+	ip := clientIP(r)
+	lockedUntil, err := m.limiter.LockedUntil(ctx, userAttemptKey(claims.Username), ipAttemptKey(ip))
+	if err != nil {
+		m.logger.Error("failed to check login lockout", "error", err)
+		http.Error(w, "Error validating credentials", http.StatusInternalServerError)
+		return
+	}
+	if !lockedUntil.IsZero() {
+		writeLockedOut(w, lockedUntil)
+		return
+	}
+
+	userID, err := claims.UserID()
+	if err != nil {
+		http.Error(w, "Invalid challenge token", http.StatusUnauthorized)
+		return
+	}
+	user, err := m.userStore.GetUserByID(ctx, userID)
+	if errors.Is(err, apperrors.ErrNotFound) || (err == nil && user.Status == model.UserStatusDeleted) {
+		http.Error(w, "Invalid challenge token", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error validating credentials", http.StatusInternalServerError)
+		return
+	}
+	if apiErr := checkAccountStatus(user); apiErr != nil {
+		writeJSONError(w, apiErr)
+		return
+	}
+
+	valid, err := m.verifySecondFactor(r, user, req)
+	if err != nil {
+		m.logger.Error("failed to verify second factor", "error", err, "user", user.Username)
+		http.Error(w, "Error validating credentials", http.StatusInternalServerError)
+		return
+	}
+	if !valid {
+		delay, err := m.limiter.RecordFailure(ctx, user.Username, ip)
+		if err != nil {
+			m.logger.Error("failed to record login failure", "error", err)
+		}
+		sleepContext(ctx, delay)
+		http.Error(w, "Invalid two-factor code", http.StatusUnauthorized)
+		return
+	}
+
+	if err = m.limiter.Reset(ctx, userAttemptKey(user.Username)); err != nil {
+		m.logger.Warn("failed to reset login failures", "error", err, "user", user.Username)
+	}
+	// Challenge-токен одноразовый
+	if err = m.revocations.Revoke(ctx, claims.ID, claims.ExpiresAt.Time); err != nil {
+		m.logger.Error("failed to revoke challenge token", "error", err, "user", user.Username)
+		http.Error(w, "Error generating tokens", http.StatusInternalServerError)
+		return
+	}
+
+	tokens, err := m.generateTokenPair(r, user, "")
+	if err != nil {
+		http.Error(w, "Error generating tokens", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// verifySecondFactor проверяет код TOTP или, если он не передан, код восстановления
+func (m *JWTMiddleware) verifySecondFactor(r *http.Request, user *model.User, req TwoFactorLoginRequest) (bool, error) {
+	ctx := r.Context()
+
+	totp, err := m.twoFactor.GetTOTP(ctx, user.ID)
+	if errors.Is(err, apperrors.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if totp.ConfirmedAt == nil {
+		return false, nil
+	}
+
+	if req.Code != "" {
+		step, valid := validateTOTP(totp.Secret, strings.TrimSpace(req.Code), time.Now())
+		if !valid {
+			return false, nil
+		}
+		err = m.twoFactor.UseTOTPStep(ctx, user.ID, step)
+		if errors.Is(err, apperrors.ErrTokenReused) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+
+	if req.RecoveryCode == "" {
+		return false, nil
+	}
+	err = m.twoFactor.UseRecoveryCode(ctx, user.ID, hashRecoveryCode(req.RecoveryCode))
+	if errors.Is(err, apperrors.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	m.logger.Warn("recovery code used for login", "user", user.Username, "remote_addr", r.RemoteAddr)
+	return true, nil
+}
+
+// twoFactorEnabled сообщает, нужно ли запрашивать у пользователя второй фактор
+func (m *JWTMiddleware) twoFactorEnabled(r *http.Request, user *model.User) (bool, error) {
+	totp, err := m.twoFactor.GetTOTP(r.Context(), user.ID)
+	if errors.Is(err, apperrors.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return totp.ConfirmedAt != nil, nil
+}
+
+// Challenge-токен подписывается секретом refresh-токенов и имеет собственную
+// аудиторию, поэтому его нельзя использовать ни как access-, ни как refresh-токен
+func (m *JWTMiddleware) challengeAudience() string {
+	return m.tokens.Audience + ":2fa"
+}
+
+func (m *JWTMiddleware) newChallengeToken(user *model.User) (*TwoFactorChallengeResponse, error) {
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		Username: user.Username,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        newTokenID(),
+			Subject:   strconv.FormatInt(user.ID, 10),
+			Issuer:    m.tokens.Issuer,
+			Audience:  jwt.ClaimStrings{m.challengeAudience()},
+			ExpiresAt: jwt.NewNumericDate(now.Add(twoFactorChallengeTTL)),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	})
+
+	signed, err := token.SignedString(m.refreshSecret)
+	if err != nil {
+		return nil, err
+	}
+	return &TwoFactorChallengeResponse{
+		TwoFactorRequired: true,
+		ChallengeToken:    signed,
+		ExpiresIn:         int64(twoFactorChallengeTTL.Seconds()),
+	}, nil
+}
+
+func (m *JWTMiddleware) parseChallengeToken(tokenString string) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return m.refreshSecret, nil
+	},
+		jwt.WithIssuer(m.tokens.Issuer),
+		jwt.WithAudience(m.challengeAudience()),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(m.tokens.ClockSkew),
+	)
+	if err != nil || !token.Valid {
+		return nil, errors.New("invalid challenge token")
+	}
+
+	claims, ok := token.Claims.(*Claims)
+	if !ok || claims.ID == "" {
+		return nil, errors.New("invalid challenge token claims")
+	}
+	return claims, nil
+}
+
+// hashRecoveryCode нормализует код (регистр, дефисы, пробелы) перед хэшированием
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	return hashToken(normalized)
+}
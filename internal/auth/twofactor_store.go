@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+	apperrors "tspo_server/internal/errors"
+	"tspo_server/model"
+)
+
+type TwoFactorStore interface {
+	GetTOTP(ctx context.Context, userID int64) (*model.UserTOTP, error)
+	// SaveTOTPSecret начинает (или перезапускает) настройку. Возвращает ErrNotFound,
+	// если 2FA у пользователя уже подтверждена.
+	SaveTOTPSecret(ctx context.Context, userID int64, secret string) error
+	// ConfirmTOTP включает 2FA и заменяет коды восстановления
+	ConfirmTOTP(ctx context.Context, userID int64, step int64, recoveryCodeHashes []string) error
+	// UseTOTPStep запоминает принятый шаг. ErrTokenReused, если код этого или
+	// более позднего шага уже использовался.
+	UseTOTPStep(ctx context.Context, userID int64, step int64) error
+	// UseRecoveryCode помечает код использованным. ErrNotFound, если кода нет или он уже использован.
+	UseRecoveryCode(ctx context.Context, userID int64, codeHash string) error
+}
+
+type MemoryTwoFactorStore struct {
+	secrets       map[int64]model.UserTOTP
+	recoveryCodes map[int64]map[string]bool // хэш -> использован
+	mu            sync.Mutex
+}
+
+func NewMemoryTwoFactorStore() *MemoryTwoFactorStore {
+	return &MemoryTwoFactorStore{
+		secrets:       make(map[int64]model.UserTOTP),
+		recoveryCodes: make(map[int64]map[string]bool),
+	}
+}
+
+func (s *MemoryTwoFactorStore) GetTOTP(ctx context.Context, userID int64) (*model.UserTOTP, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	totp, exists := s.secrets[userID]
+	if !exists {
+		return nil, apperrors.ErrNotFound
+	}
+	return &totp, nil
+}
+
+func (s *MemoryTwoFactorStore) SaveTOTPSecret(ctx context.Context, userID int64, secret string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if totp, exists := s.secrets[userID]; exists && totp.ConfirmedAt != nil {
+		return apperrors.ErrNotFound
+	}
+	s.secrets[userID] = model.UserTOTP{UserID: userID, Secret: secret, CreatedAt: time.Now()}
+	return nil
+}
+
+func (s *MemoryTwoFactorStore) ConfirmTOTP(ctx context.Context, userID int64, step int64, recoveryCodeHashes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	totp, exists := s.secrets[userID]
+	if !exists || totp.ConfirmedAt != nil {
+		return apperrors.ErrNotFound
+	}
+	now := time.Now()
+	totp.ConfirmedAt = &now
+	totp.LastStep = step
+	s.secrets[userID] = totp
+
+	codes := make(map[string]bool, len(recoveryCodeHashes))
+	for _, hash := range recoveryCodeHashes {
+		codes[hash] = false
+	}
+	s.recoveryCodes[userID] = codes
+	return nil
+}
+
+func (s *MemoryTwoFactorStore) UseTOTPStep(ctx context.Context, userID int64, step int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	totp, exists := s.secrets[userID]
+	if !exists {
+		return apperrors.ErrNotFound
+	}
+	if step <= totp.LastStep {
+		return apperrors.ErrTokenReused
+	}
+	totp.LastStep = step
+	s.secrets[userID] = totp
+	return nil
+}
+
+func (s *MemoryTwoFactorStore) UseRecoveryCode(ctx context.Context, userID int64, codeHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	used, exists := s.recoveryCodes[userID][codeHash]
+	if !exists || used {
+		return apperrors.ErrNotFound
+	}
+	s.recoveryCodes[userID][codeHash] = true
+	return nil
+}
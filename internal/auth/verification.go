@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strings"
+	"sync"
+	"time"
+	apperrors "tspo_server/internal/errors"
+	"tspo_server/internal/notify"
+	"tspo_server/model"
+)
+
+// Машиночитаемые причины отказа во входе (APIError.ErrorCode)
+const (
+	ErrorCodeAccountDisabled  = "account_disabled"
+	ErrorCodeEmailNotVerified = "email_not_verified"
+)
+
+type EmailVerificationStore interface {
+	// CreateVerificationToken сохраняет токен и делает недействительными прежние токены пользователя
+	CreateVerificationToken(ctx context.Context, token *model.EmailVerificationToken) error
+	// ConsumeVerificationToken атомарно помечает токен использованным и возвращает id
+	// пользователя. ErrNotFound, если токена нет, он уже использован или истёк.
+	ConsumeVerificationToken(ctx context.Context, tokenHash string) (int64, error)
+}
+
+// VerifyEmail подтверждает email по токену из письма и активирует учётную запись
+func (m *JWTMiddleware) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req VerifyEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	userID, err := m.verifications.ConsumeVerificationToken(ctx, hashToken(req.Token))
+	if errors.Is(err, apperrors.ErrNotFound) {
+		http.Error(w, "Invalid or expired verification token", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error verifying email", http.StatusInternalServerError)
+		return
+	}
+
+	if err = m.userStore.MarkEmailVerified(ctx, userID); err != nil {
+		m.logger.Error("failed to mark email verified", "error", err, "user_id", userID)
+		http.Error(w, "Error verifying email", http.StatusInternalServerError)
+		return
+	}
+
+	m.logger.Info("email verified", "user_id", userID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Email verified successfully"})
+}
+
+// ResendVerification повторно отправляет письмо подтверждения.
+// Ответ одинаков независимо от существования адреса.
+func (m *JWTMiddleware) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	var req ResendVerificationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Email) == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	user, err := m.userStore.GetUserByEmail(ctx, req.Email)
+	if err == nil && user.Status == model.UserStatusPending {
+		err = m.sendVerification(ctx, user)
+	}
+	if err != nil && !errors.Is(err, apperrors.ErrNotFound) {
+		m.logger.Error("failed to resend verification email", "error", err, "email", req.Email)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "If the account exists and is not verified, a verification email has been sent",
+	})
+}
+
+func (m *JWTMiddleware) sendVerification(ctx context.Context, user *model.User) error {
+	rawToken := randomHex(32)
+	token := &model.EmailVerificationToken{
+		TokenHash: hashToken(rawToken),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(m.verificationTTL),
+	}
+	if err := m.verifications.CreateVerificationToken(ctx, token); err != nil {
+		return err
+	}
+
+	return m.notifier.Notify(ctx, notify.Message{
+		To:      user.Email,
+		Subject: "Confirm your email",
+		Body: fmt.Sprintf("Hello, %s!\nUse this token to confirm your email: %s\nThe token expires at %s.",
+			user.Username, rawToken, token.ExpiresAt.Format(time.RFC3339)),
+	})
+}
+
+// checkAccountStatus возвращает ошибку для учётных записей, которым вход запрещён.
+// Удалённые учётные записи обрабатываются вызывающим кодом как несуществующие.
+func checkAccountStatus(user *model.User) *apperrors.APIError {
+	switch user.Status {
+	case model.UserStatusActive:
+		return nil
+	case model.UserStatusPending:
+		return apperrors.NewCodedAPIError(http.StatusForbidden, ErrorCodeEmailNotVerified,
+			"Email address has not been verified")
+	}
+	return apperrors.NewCodedAPIError(http.StatusForbidden, ErrorCodeAccountDisabled, "Account is disabled")
+}
+
+func validateEmail(email string) []apperrors.FieldError {
+	if email == "" {
+		return []apperrors.FieldError{{Field: "email", Code: "required", Message: "email is required"}}
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || len(email) > 255 {
+		return []apperrors.FieldError{{Field: "email", Code: "invalid", Message: "email is not a valid address"}}
+	}
+	return nil
+}
+
+type MemoryEmailVerificationStore struct {
+	tokens map[string]model.EmailVerificationToken
+	mu     sync.Mutex
+}
+
+func NewMemoryEmailVerificationStore() *MemoryEmailVerificationStore {
+	return &MemoryEmailVerificationStore{
+		tokens: make(map[string]model.EmailVerificationToken),
+	}
+}
+
+func (s *MemoryEmailVerificationStore) CreateVerificationToken(ctx context.Context, token *model.EmailVerificationToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for hash, existing := range s.tokens {
+		if existing.UserID == token.UserID && existing.UsedAt == nil {
+			existing.UsedAt = &now
+			s.tokens[hash] = existing
+		}
+	}
+	token.CreatedAt = now
+	s.tokens[token.TokenHash] = *token
+	return nil
+}
+
+func (s *MemoryEmailVerificationStore) ConsumeVerificationToken(ctx context.Context, tokenHash string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	token, exists := s.tokens[tokenHash]
+	if !exists || token.UsedAt != nil || now.After(token.ExpiresAt) {
+		return 0, apperrors.ErrNotFound
+	}
+	token.UsedAt = &now
+	s.tokens[tokenHash] = token
+	return token.UserID, nil
+}
@@ -2,6 +2,9 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Configuration struct {
@@ -16,6 +19,61 @@ type Configuration struct {
 	DBName           string
 	JWTSecret        string
 	JWTRefreshSecret string
+	AdminUsername    string
+	AdminPassword    string
+
+	JWTAccessTTL            time.Duration
+	JWTRefreshTTL           time.Duration
+	JWTIssuer               string
+	JWTAudience             string
+	JWTClockSkew            time.Duration
+	JWTSigningKeyFile       string   // PEM с закрытым ключом (RSA, ECDSA, Ed25519)
+	JWTVerificationKeyFiles []string // PEM предыдущих ключей, которые ещё принимаются
+
+	RevocationPruneInterval time.Duration
+
+	LoginMaxUserFailures int
+	LoginMaxIPFailures   int
+	LoginFailureWindow   time.Duration
+	LoginLockoutDuration time.Duration
+	LoginBaseDelay       time.Duration
+	LoginMaxDelay        time.Duration
+
+	PasswordResetTTL     time.Duration
+	EmailVerificationTTL time.Duration
+	Notifier             string // log, file, smtp
+	NotifierFile         string
+	SMTPAddr             string
+	SMTPFrom             string
+	SMTPUsername         string
+	SMTPPassword         string
+
+	OIDCIssuerURL    string // вход через OIDC включается, если задан
+	OIDCClientID     string
+	OIDCClientSecret string
+	OIDCRedirectURL  string
+	OIDCScopes       []string
+	OIDCGroupsClaim  string
+	OIDCRoleMapping  []string // пары группа=роль
+
+	RequireIfMatch bool // PUT, PATCH и DELETE книг требуют If-Match
+
+	TrashRetention     time.Duration // сколько книги хранятся в корзине до безвозвратного удаления
+	TrashPurgeInterval time.Duration
+
+	PasswordHashAlgorithm string // bcrypt, argon2id
+	BcryptCost            int
+	Argon2Time            int
+	Argon2MemoryKB        int
+	Argon2Threads         int
+
+	PasswordMinLength     int
+	PasswordMaxLength     int
+	PasswordRequireUpper  bool
+	PasswordRequireLower  bool
+	PasswordRequireDigit  bool
+	PasswordRequireSymbol bool
+	PasswordBanned        []string
 }
 
 // Construct() использует метод os.LookupEnv() для получения значений переменных окружения.
@@ -29,7 +87,101 @@ func (c *Configuration) Construct() {
 	c.DBUser = os.Getenv("DB_USER")
 	c.DBPass = os.Getenv("DB_PASSWORD")
 	c.DBName = os.Getenv("DB_NAME")
-	c.JWTSecret = "your-secret-key"
-	c.JWTRefreshSecret = "your-refresh-secret-key"
+	c.JWTSecret = getEnv("JWT_SECRET", "your-secret-key")
+	c.JWTRefreshSecret = getEnv("JWT_REFRESH_SECRET", "your-refresh-secret-key")
+	c.JWTAccessTTL = getEnvDuration("JWT_ACCESS_TTL", 15*time.Minute)
+	c.JWTRefreshTTL = getEnvDuration("JWT_REFRESH_TTL", 7*24*time.Hour)
+	c.JWTIssuer = getEnv("JWT_ISSUER", "tspo_server")
+	c.JWTAudience = getEnv("JWT_AUDIENCE", "tspo_server")
+	c.JWTClockSkew = getEnvDuration("JWT_CLOCK_SKEW", 30*time.Second)
+	c.JWTSigningKeyFile = os.Getenv("JWT_SIGNING_KEY_FILE")
+	c.JWTVerificationKeyFiles = getEnvList("JWT_VERIFICATION_KEY_FILES")
+	c.AdminUsername = os.Getenv("ADMIN_USERNAME")
+	c.AdminPassword = os.Getenv("ADMIN_PASSWORD")
+	c.RevocationPruneInterval = getEnvDuration("REVOCATION_PRUNE_INTERVAL", 10*time.Minute)
+
+	c.LoginMaxUserFailures = getEnvInt("LOGIN_MAX_USER_FAILURES", 5)
+	c.LoginMaxIPFailures = getEnvInt("LOGIN_MAX_IP_FAILURES", 20)
+	c.LoginFailureWindow = getEnvDuration("LOGIN_FAILURE_WINDOW", 15*time.Minute)
+	c.LoginLockoutDuration = getEnvDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute)
+	c.LoginBaseDelay = getEnvDuration("LOGIN_BASE_DELAY", 250*time.Millisecond)
+	c.LoginMaxDelay = getEnvDuration("LOGIN_MAX_DELAY", 5*time.Second)
+
+	c.PasswordResetTTL = getEnvDuration("PASSWORD_RESET_TTL", 30*time.Minute)
+	c.EmailVerificationTTL = getEnvDuration("EMAIL_VERIFICATION_TTL", 24*time.Hour)
+	c.Notifier = getEnv("NOTIFIER", "log")
+	c.NotifierFile = getEnv("NOTIFIER_FILE", "notifications.log")
+	c.SMTPAddr = os.Getenv("SMTP_ADDR")
+	c.SMTPFrom = getEnv("SMTP_FROM", "no-reply@tspo.local")
+	c.SMTPUsername = os.Getenv("SMTP_USERNAME")
+	c.SMTPPassword = os.Getenv("SMTP_PASSWORD")
+
+	c.OIDCIssuerURL = os.Getenv("OIDC_ISSUER_URL")
+	c.OIDCClientID = os.Getenv("OIDC_CLIENT_ID")
+	c.OIDCClientSecret = os.Getenv("OIDC_CLIENT_SECRET")
+	c.OIDCRedirectURL = os.Getenv("OIDC_REDIRECT_URL")
+	c.OIDCScopes = getEnvList("OIDC_SCOPES")
+	c.OIDCGroupsClaim = getEnv("OIDC_GROUPS_CLAIM", "groups")
+	c.OIDCRoleMapping = getEnvList("OIDC_ROLE_MAPPING")
+
+	c.RequireIfMatch = getEnvBool("REQUIRE_IF_MATCH", true)
+
+	c.TrashRetention = getEnvDuration("TRASH_RETENTION", 30*24*time.Hour)
+	c.TrashPurgeInterval = getEnvDuration("TRASH_PURGE_INTERVAL", time.Hour)
+
+	c.PasswordHashAlgorithm = getEnv("PASSWORD_HASH_ALGORITHM", "bcrypt")
+	c.BcryptCost = getEnvInt("BCRYPT_COST", 12)
+	c.Argon2Time = getEnvInt("ARGON2_TIME", 3)
+	c.Argon2MemoryKB = getEnvInt("ARGON2_MEMORY_KB", 64*1024)
+	c.Argon2Threads = getEnvInt("ARGON2_THREADS", 2)
+
+	c.PasswordMinLength = getEnvInt("PASSWORD_MIN_LENGTH", 8)
+	c.PasswordMaxLength = getEnvInt("PASSWORD_MAX_LENGTH", 72) // ограничение bcrypt
+	c.PasswordRequireUpper = getEnvBool("PASSWORD_REQUIRE_UPPER", true)
+	c.PasswordRequireLower = getEnvBool("PASSWORD_REQUIRE_LOWER", true)
+	c.PasswordRequireDigit = getEnvBool("PASSWORD_REQUIRE_DIGIT", true)
+	c.PasswordRequireSymbol = getEnvBool("PASSWORD_REQUIRE_SYMBOL", false)
+	c.PasswordBanned = getEnvList("PASSWORD_BANNED_LIST")
+}
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
 
+// getEnvList разбирает список значений, разделённых запятыми
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
 }
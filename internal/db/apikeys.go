@@ -0,0 +1,87 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/lib/pq"
+	"tspo_server/internal/errors"
+	"tspo_server/model"
+)
+
+type APIKeyRepository struct {
+	db *sql.DB
+}
+
+func NewAPIKeyRepository(db *sql.DB) (*APIKeyRepository, error) {
+	return &APIKeyRepository{db: db}, nil
+}
+
+const apiKeyColumns = "id, user_id, name, prefix, key_hash, scopes, expires_at, last_used_at, created_at, revoked_at"
+
+func scanAPIKey(row interface{ Scan(...interface{}) error }, key *model.APIKey) error {
+	return row.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &key.KeyHash, pq.Array(&key.Scopes),
+		&key.ExpiresAt, &key.LastUsedAt, &key.CreatedAt, &key.RevokedAt)
+}
+
+func (r *APIKeyRepository) CreateAPIKey(ctx context.Context, key *model.APIKey) error {
+	err := r.db.QueryRowContext(ctx,
+		`INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expires_at)
+		 VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`,
+		key.UserID, key.Name, key.Prefix, key.KeyHash, pq.Array(key.Scopes), key.ExpiresAt).
+		Scan(&key.ID, &key.CreatedAt)
+
+	if err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	return nil
+}
+
+func (r *APIKeyRepository) GetAPIKeyByPrefix(ctx context.Context, prefix string) (*model.APIKey, error) {
+	var key model.APIKey
+	err := scanAPIKey(r.db.QueryRowContext(ctx,
+		"SELECT "+apiKeyColumns+" FROM api_keys WHERE prefix = $1", prefix), &key)
+
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+
+	return &key, nil
+}
+
+func (r *APIKeyRepository) ListUserAPIKeys(ctx context.Context, userID int64) ([]model.APIKey, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT "+apiKeyColumns+" FROM api_keys WHERE user_id = $1 ORDER BY created_at DESC", userID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	defer rows.Close()
+
+	var keys []model.APIKey
+	for rows.Next() {
+		var key model.APIKey
+		if err = scanAPIKey(rows, &key); err != nil {
+			return nil, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func (r *APIKeyRepository) RevokeAPIKey(ctx context.Context, id, userID int64) error {
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE api_keys SET revoked_at = COALESCE(revoked_at, now()) WHERE id = $1 AND user_id = $2",
+		id, userID)
+
+	return checkAffected(result, err)
+}
+
+func (r *APIKeyRepository) TouchAPIKey(ctx context.Context, id int64) error {
+	result, err := r.db.ExecContext(ctx, "UPDATE api_keys SET last_used_at = now() WHERE id = $1", id)
+
+	return checkAffected(result, err)
+}
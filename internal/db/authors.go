@@ -0,0 +1,317 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"strings"
+	"tspo_server/internal/errors"
+	"tspo_server/internal/query"
+	"tspo_server/model"
+)
+
+type AuthorRepository struct {
+	db *sql.DB
+}
+
+func NewAuthorRepository(db *sql.DB) (*AuthorRepository, error) {
+	return &AuthorRepository{db: db}, nil
+}
+
+const authorColumns = "id, name, COALESCE(bio, ''), created_at, updated_at"
+
+func scanAuthor(row interface{ Scan(...interface{}) error }, author *model.Author) error {
+	return row.Scan(&author.ID, &author.Name, &author.Bio, &author.CreatedAt, &author.UpdatedAt)
+}
+
+// authorBooksSQL выбирает id книг автора $1
+const authorBooksSQL = "SELECT book_id FROM book_authors WHERE author_id = $1"
+
+// maxAuthorLineLength - размер books.author. Полный список авторов хранится в book_authors,
+// поэтому слишком длинная строка просто обрезается.
+const maxAuthorLineLength = 255
+
+// authorLineSQL пересчитывает books.author у книг автора $1 после переименования
+// по тем же правилам, что и authorLine, включая обрезку до maxAuthorLineLength, и увеличивает
+// их версию: имя в authors меняется у всех этих книг, даже если строка author осталась прежней
+const authorLineSQL = `UPDATE books b SET author = left(s.line, 255), updated_at = now(), version = b.version + 1
+	FROM (SELECT ba.book_id,
+	             COALESCE(string_agg(a.name, ', ' ORDER BY ba.position) FILTER (WHERE ba.role = 'author'),
+	                      string_agg(a.name, ', ' ORDER BY ba.position)) AS line
+	      FROM book_authors ba JOIN authors a ON a.id = ba.author_id
+	      WHERE ba.book_id IN (SELECT book_id FROM book_authors WHERE author_id = $1)
+	      GROUP BY ba.book_id) s
+	WHERE b.id = s.book_id`
+
+// authorLine собирает строку books.author: имена с ролью author в порядке на обложке,
+// а если таких нет - все имена. Строка нужна для поиска и сортировки книг по автору.
+// Строка обрезается до maxAuthorLineLength символов.
+func authorLine(authors []model.BookAuthor) string {
+	var names, all []string
+	for _, author := range authors {
+		all = append(all, author.Name)
+		if author.Role == model.AuthorRoleAuthor {
+			names = append(names, author.Name)
+		}
+	}
+	if len(names) == 0 {
+		names = all
+	}
+	line := []rune(strings.Join(names, ", "))
+	if len(line) > maxAuthorLineLength {
+		line = line[:maxAuthorLineLength]
+	}
+	return string(line)
+}
+
+func (r *AuthorRepository) ListAuthors(ctx context.Context, params *query.Params) ([]model.Author, int, error) {
+	whereClause := []string{}
+	args := []interface{}{}
+	argCount := 1
+
+	for key, value := range params.Filter {
+		whereClause = append(whereClause, fmt.Sprintf("%s ILIKE $%d", key, argCount))
+		args = append(args, "%"+value+"%")
+		argCount++
+	}
+
+	where := ""
+	if len(whereClause) > 0 {
+		where = " WHERE " + strings.Join(whereClause, " AND ")
+	}
+
+	var total int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM authors"+where, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+
+	query := "SELECT " + authorColumns + " FROM authors" + where +
+		fmt.Sprintf(" ORDER BY %s %s LIMIT $%d OFFSET $%d", params.Sort, params.Order, argCount, argCount+1)
+	args = append(args, params.PageSize, (params.Page-1)*params.PageSize)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	defer rows.Close()
+
+	var authors []model.Author
+	for rows.Next() {
+		var author model.Author
+		if err = scanAuthor(rows, &author); err != nil {
+			return nil, 0, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+		}
+		authors = append(authors, author)
+	}
+
+	return authors, total, nil
+}
+
+func (r *AuthorRepository) GetAuthor(ctx context.Context, id string) (*model.Author, error) {
+	var author model.Author
+	err := scanAuthor(r.db.QueryRowContext(ctx, "SELECT "+authorColumns+" FROM authors WHERE id = $1", id), &author)
+
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+
+	return &author, nil
+}
+
+func (r *AuthorRepository) CreateAuthor(ctx context.Context, author *model.Author) error {
+	err := r.db.QueryRowContext(ctx,
+		"INSERT INTO authors (id, name, bio) VALUES ($1, $2, NULLIF($3, '')) RETURNING created_at, updated_at",
+		author.ID, author.Name, author.Bio).
+		Scan(&author.CreatedAt, &author.UpdatedAt)
+
+	if isUniqueViolation(err) {
+		return errors.ErrConflict
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	return nil
+}
+
+// UpdateAuthor сохраняет автора. При переименовании обновляет связанные книги
+// и записывает их изменение в историю от имени actor.
+func (r *AuthorRepository) UpdateAuthor(ctx context.Context, author *model.Author, actor string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	defer tx.Rollback()
+
+	// Блокировка автора не даёт параллельно привязать его к другим книгам,
+	// поэтому каждая книга после переименования есть и в before
+	var name string
+	err = tx.QueryRowContext(ctx, "SELECT name FROM authors WHERE id = $1 FOR UPDATE", author.ID).Scan(&name)
+	if err == sql.ErrNoRows {
+		return errors.ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+
+	var before []model.Book
+	renamed := name != author.Name
+	if renamed {
+		if before, err = linkedBooks(ctx, tx, authorBooksSQL, author.ID, " FOR UPDATE"); err != nil {
+			return err
+		}
+	}
+
+	err = scanAuthor(tx.QueryRowContext(ctx,
+		`UPDATE authors SET name = $1, bio = NULLIF($2, ''), updated_at = now()
+		 WHERE id = $3
+		 RETURNING `+authorColumns,
+		author.Name, author.Bio, author.ID), author)
+	if isUniqueViolation(err) {
+		return errors.ErrConflict
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+
+	if renamed {
+		if _, err = tx.ExecContext(ctx, authorLineSQL, author.ID); err != nil {
+			return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+		}
+		after, err := linkedBooks(ctx, tx, authorBooksSQL, author.ID, "")
+		if err != nil {
+			return err
+		}
+		if err = writeLinkedHistory(ctx, tx, actor, before, after); err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	return nil
+}
+
+func (r *AuthorRepository) DeleteAuthor(ctx context.Context, id string) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM authors WHERE id = $1", id)
+	if isForeignKeyViolation(err) {
+		return errors.ErrConflict
+	}
+
+	return checkAffected(result, err)
+}
+
+// resolveAuthors находит авторов книги по id или по имени. Авторы, переданные только
+// по имени, создаются. Если Authors пуст, список берётся из Author (имена через запятую),
+// как в старом формате запроса.
+func resolveAuthors(ctx context.Context, tx *sql.Tx, book *model.Book) error {
+	if len(book.Authors) == 0 {
+		for _, name := range strings.Split(book.Author, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				book.Authors = append(book.Authors, model.BookAuthor{Name: name, Role: model.AuthorRoleAuthor})
+			}
+		}
+	}
+
+	for i := range book.Authors {
+		author := &book.Authors[i]
+		if author.Role == "" {
+			author.Role = model.AuthorRoleAuthor
+		}
+
+		if author.ID != "" {
+			err := tx.QueryRowContext(ctx, "SELECT name FROM authors WHERE id = $1", author.ID).Scan(&author.Name)
+			if err == sql.ErrNoRows {
+				return errors.NewFieldValidationError(fmt.Sprintf("authors[%d].id", i), "not_found",
+					"author "+author.ID+" does not exist")
+			}
+			if err != nil {
+				return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+			}
+			continue
+		}
+
+		err := tx.QueryRowContext(ctx,
+			"SELECT id FROM authors WHERE name = $1 ORDER BY created_at LIMIT 1", author.Name).Scan(&author.ID)
+		if err == nil {
+			continue
+		}
+		if err != sql.ErrNoRows {
+			return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+		}
+
+		id, err := uuid.NewV7()
+		if err != nil {
+			return err
+		}
+		author.ID = id.String()
+		if _, err = tx.ExecContext(ctx, "INSERT INTO authors (id, name) VALUES ($1, $2)", author.ID, author.Name); err != nil {
+			return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+		}
+	}
+	return nil
+}
+
+// writeBookAuthors заменяет связи книги с авторами
+func writeBookAuthors(ctx context.Context, tx *sql.Tx, book *model.Book) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM book_authors WHERE book_id = $1", book.ID); err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	for i, author := range book.Authors {
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO book_authors (book_id, author_id, position, role) VALUES ($1, $2, $3, $4)",
+			book.ID, author.ID, i+1, author.Role); err != nil {
+			if isUniqueViolation(err) {
+				return errors.NewFieldValidationError(fmt.Sprintf("authors[%d]", i), "duplicate",
+					"author is listed twice with the same role")
+			}
+			return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+		}
+	}
+	return nil
+}
+
+// loadBookAuthors заполняет Authors у книг одним запросом
+func loadBookAuthors(ctx context.Context, q queryer, books []model.Book) error {
+	ids := make([]string, len(books))
+	index := make(map[string]int, len(books))
+	for i := range books {
+		ids[i] = books[i].ID
+		index[books[i].ID] = i
+		books[i].Authors = []model.BookAuthor{}
+	}
+	if len(books) == 0 {
+		return nil
+	}
+
+	rows, err := q.QueryContext(ctx,
+		`SELECT ba.book_id, a.id, a.name, ba.role
+		 FROM book_authors ba JOIN authors a ON a.id = ba.author_id
+		 WHERE ba.book_id = ANY($1)
+		 ORDER BY ba.book_id, ba.position`,
+		pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var bookID string
+		var author model.BookAuthor
+		if err = rows.Scan(&bookID, &author.ID, &author.Name, &author.Role); err != nil {
+			return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+		}
+		book := &books[index[bookID]]
+		book.Authors = append(book.Authors, author)
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	return nil
+}
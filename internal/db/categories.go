@@ -0,0 +1,277 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/lib/pq"
+	"strings"
+	"tspo_server/internal/errors"
+	"tspo_server/internal/query"
+	"tspo_server/model"
+)
+
+type CategoryRepository struct {
+	db *sql.DB
+}
+
+func NewCategoryRepository(db *sql.DB) (*CategoryRepository, error) {
+	return &CategoryRepository{db: db}, nil
+}
+
+const categoryColumns = "id, COALESCE(parent_id, ''), name, COALESCE(description, ''), created_at, updated_at"
+
+// categoryTreeSQL выбирает id рубрики $n и всех её потомков
+const categoryTreeSQL = `WITH RECURSIVE tree AS (
+		SELECT id FROM categories WHERE id = $%d
+		UNION ALL
+		SELECT c.id FROM categories c JOIN tree t ON c.parent_id = t.id
+	) SELECT id FROM tree`
+
+// categoryBooksSQL выбирает id книг рубрики $1
+const categoryBooksSQL = "SELECT book_id FROM book_categories WHERE category_id = $1"
+
+func scanCategory(row interface{ Scan(...interface{}) error }, category *model.Category) error {
+	return row.Scan(&category.ID, &category.ParentID, &category.Name, &category.Description,
+		&category.CreatedAt, &category.UpdatedAt)
+}
+
+// ListCategories возвращает страницу рубрик. name ищется по подстроке, parent_id
+// сравнивается точно; parent_id=root выбирает корневые рубрики.
+func (r *CategoryRepository) ListCategories(ctx context.Context, params *query.Params) ([]model.Category, int, error) {
+	whereClause := []string{}
+	args := []interface{}{}
+	argCount := 1
+
+	for key, value := range params.Filter {
+		switch {
+		case key == "parent_id" && value == "root":
+			whereClause = append(whereClause, "parent_id IS NULL")
+			continue
+		case key == "parent_id":
+			whereClause = append(whereClause, fmt.Sprintf("parent_id = $%d", argCount))
+			args = append(args, value)
+		default:
+			whereClause = append(whereClause, fmt.Sprintf("%s ILIKE $%d", key, argCount))
+			args = append(args, "%"+value+"%")
+		}
+		argCount++
+	}
+
+	where := ""
+	if len(whereClause) > 0 {
+		where = " WHERE " + strings.Join(whereClause, " AND ")
+	}
+
+	var total int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM categories"+where, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+
+	query := "SELECT " + categoryColumns + " FROM categories" + where +
+		fmt.Sprintf(" ORDER BY %s %s LIMIT $%d OFFSET $%d", params.Sort, params.Order, argCount, argCount+1)
+	args = append(args, params.PageSize, (params.Page-1)*params.PageSize)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	defer rows.Close()
+
+	var categories []model.Category
+	for rows.Next() {
+		var category model.Category
+		if err = scanCategory(rows, &category); err != nil {
+			return nil, 0, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+		}
+		categories = append(categories, category)
+	}
+
+	return categories, total, nil
+}
+
+func (r *CategoryRepository) GetCategory(ctx context.Context, id string) (*model.Category, error) {
+	var category model.Category
+	err := scanCategory(r.db.QueryRowContext(ctx, "SELECT "+categoryColumns+" FROM categories WHERE id = $1", id), &category)
+
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+
+	return &category, nil
+}
+
+// CreateCategory возвращает ErrInvalidInput, если родитель не существует,
+// и ErrConflict, если у родителя уже есть рубрика с таким именем
+func (r *CategoryRepository) CreateCategory(ctx context.Context, category *model.Category) error {
+	err := r.db.QueryRowContext(ctx,
+		`INSERT INTO categories (id, parent_id, name, description)
+		 VALUES ($1, NULLIF($2, ''), $3, NULLIF($4, ''))
+		 RETURNING created_at, updated_at`,
+		category.ID, category.ParentID, category.Name, category.Description).
+		Scan(&category.CreatedAt, &category.UpdatedAt)
+
+	return categoryWriteError(err)
+}
+
+// UpdateCategory дополнительно проверяет, что новый родитель не является самой
+// рубрикой или её потомком, иначе дерево превратилось бы в цикл. При переименовании
+// увеличивает версию книг рубрики и записывает их изменение в историю от имени actor.
+func (r *CategoryRepository) UpdateCategory(ctx context.Context, category *model.Category, actor string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	defer tx.Rollback()
+
+	// Блокировка рубрики не даёт параллельно привязать её к другим книгам
+	var name string
+	err = tx.QueryRowContext(ctx, "SELECT name FROM categories WHERE id = $1 FOR UPDATE", category.ID).Scan(&name)
+	if err == sql.ErrNoRows {
+		return errors.ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+
+	if category.ParentID != "" {
+		var cycle bool
+		err = tx.QueryRowContext(ctx,
+			"SELECT $2 IN ("+fmt.Sprintf(categoryTreeSQL, 1)+")", category.ID, category.ParentID).Scan(&cycle)
+		if err != nil {
+			return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+		}
+		if cycle {
+			return fmt.Errorf("%w: category cannot be its own ancestor", errors.ErrInvalidInput)
+		}
+	}
+
+	var before []model.Book
+	renamed := name != category.Name
+	if renamed {
+		if before, err = linkedBooks(ctx, tx, categoryBooksSQL, category.ID, " FOR UPDATE"); err != nil {
+			return err
+		}
+	}
+
+	err = scanCategory(tx.QueryRowContext(ctx,
+		`UPDATE categories SET parent_id = NULLIF($1, ''), name = $2, description = NULLIF($3, ''), updated_at = now()
+		 WHERE id = $4
+		 RETURNING `+categoryColumns,
+		category.ParentID, category.Name, category.Description, category.ID), category)
+	if err = categoryWriteError(err); err != nil {
+		return err
+	}
+
+	if renamed {
+		_, err = tx.ExecContext(ctx,
+			"UPDATE books SET updated_at = now(), version = version + 1 WHERE id IN ("+categoryBooksSQL+")",
+			category.ID)
+		if err != nil {
+			return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+		}
+		after, err := linkedBooks(ctx, tx, categoryBooksSQL, category.ID, "")
+		if err != nil {
+			return err
+		}
+		if err = writeLinkedHistory(ctx, tx, actor, before, after); err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	return nil
+}
+
+// DeleteCategory удаляет рубрику без подрубрик и книг. Иначе возвращает ErrConflict.
+func (r *CategoryRepository) DeleteCategory(ctx context.Context, id string) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM categories WHERE id = $1", id)
+	if isForeignKeyViolation(err) {
+		return errors.ErrConflict
+	}
+
+	return checkAffected(result, err)
+}
+
+func categoryWriteError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case isUniqueViolation(err):
+		return errors.ErrConflict
+	case isForeignKeyViolation(err):
+		return fmt.Errorf("%w: unknown parent category", errors.ErrInvalidInput)
+	}
+	return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+}
+
+// writeBookCategories заменяет рубрики книги и подставляет их названия
+func writeBookCategories(ctx context.Context, tx *sql.Tx, book *model.Book) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM book_categories WHERE book_id = $1", book.ID); err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	for i := range book.Categories {
+		category := &book.Categories[i]
+		err := tx.QueryRowContext(ctx,
+			`INSERT INTO book_categories (book_id, category_id)
+			 SELECT $1, id FROM categories WHERE id = $2
+			 RETURNING (SELECT name FROM categories WHERE id = $2)`,
+			book.ID, category.ID).Scan(&category.Name)
+		if err == sql.ErrNoRows {
+			return errors.NewFieldValidationError(fmt.Sprintf("categories[%d].id", i), "not_found",
+				"category "+category.ID+" does not exist")
+		}
+		if isUniqueViolation(err) {
+			return errors.NewFieldValidationError(fmt.Sprintf("categories[%d]", i), "duplicate", "category is listed twice")
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+		}
+	}
+	return nil
+}
+
+// loadBookCategories заполняет Categories у книг одним запросом
+func loadBookCategories(ctx context.Context, q queryer, books []model.Book) error {
+	ids := make([]string, len(books))
+	index := make(map[string]int, len(books))
+	for i := range books {
+		ids[i] = books[i].ID
+		index[books[i].ID] = i
+		books[i].Categories = []model.BookCategory{}
+	}
+	if len(books) == 0 {
+		return nil
+	}
+
+	rows, err := q.QueryContext(ctx,
+		`SELECT bc.book_id, c.id, c.name
+		 FROM book_categories bc JOIN categories c ON c.id = bc.category_id
+		 WHERE bc.book_id = ANY($1)
+		 ORDER BY bc.book_id, c.name`,
+		pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var bookID string
+		var category model.BookCategory
+		if err = rows.Scan(&bookID, &category.ID, &category.Name); err != nil {
+			return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+		}
+		book := &books[index[bookID]]
+		book.Categories = append(book.Categories, category)
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"tspo_server/internal/errors"
+	"tspo_server/model"
+)
+
+type EmailVerificationRepository struct {
+	db *sql.DB
+}
+
+func NewEmailVerificationRepository(db *sql.DB) (*EmailVerificationRepository, error) {
+	return &EmailVerificationRepository{db: db}, nil
+}
+
+func (r *EmailVerificationRepository) CreateVerificationToken(ctx context.Context, token *model.EmailVerificationToken) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(ctx,
+		"UPDATE email_verification_tokens SET used_at = now() WHERE user_id = $1 AND used_at IS NULL",
+		token.UserID); err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO email_verification_tokens (token_hash, user_id, expires_at)
+		 VALUES ($1, $2, $3) RETURNING created_at`,
+		token.TokenHash, token.UserID, token.ExpiresAt).
+		Scan(&token.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	return nil
+}
+
+func (r *EmailVerificationRepository) ConsumeVerificationToken(ctx context.Context, tokenHash string) (int64, error) {
+	var userID int64
+	err := r.db.QueryRowContext(ctx,
+		`UPDATE email_verification_tokens SET used_at = now()
+		 WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now()
+		 RETURNING user_id`,
+		tokenHash).Scan(&userID)
+
+	if err == sql.ErrNoRows {
+		return 0, errors.ErrNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	return userID, nil
+}
@@ -0,0 +1,41 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	apperrors "tspo_server/internal/errors"
+)
+
+// Коды ошибок PostgreSQL unique_violation и foreign_key_violation
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+)
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == pgUniqueViolation
+}
+
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == pgForeignKeyViolation
+}
+
+// checkAffected оборачивает ошибку Exec и возвращает ErrNotFound, если ни одна строка не изменилась
+func checkAffected(result sql.Result, err error) error {
+	if err != nil {
+		return fmt.Errorf("%w: %v", apperrors.ErrDatabaseOperation, err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%w: %v", apperrors.ErrDatabaseOperation, err)
+	}
+	if rows == 0 {
+		return apperrors.ErrNotFound
+	}
+	return nil
+}
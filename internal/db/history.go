@@ -0,0 +1,251 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"tspo_server/internal/errors"
+	"tspo_server/internal/query"
+	"tspo_server/model"
+)
+
+const historyColumns = "id, book_id, version, action, actor, changed_at, changes, snapshot"
+
+// Поля, которые меняются при каждой записи и не попадают в changes
+var historyIgnoredFields = map[string]bool{"version": true, "updated_at": true, "etag": true}
+
+func scanHistoryEntry(row interface{ Scan(...interface{}) error }, entry *model.BookHistoryEntry) error {
+	var changes, snapshot []byte
+	err := row.Scan(&entry.ID, &entry.BookID, &entry.Version, &entry.Action, &entry.Actor, &entry.ChangedAt,
+		&changes, &snapshot)
+	if err != nil {
+		return err
+	}
+	entry.Changes = json.RawMessage(changes)
+	if snapshot != nil {
+		entry.Snapshot = json.RawMessage(snapshot)
+	}
+	return nil
+}
+
+// writeHistory добавляет запись в журнал в транзакции изменения книги.
+// before == nil означает создание книги, after == nil - безвозвратное удаление.
+func writeHistory(ctx context.Context, tx *sql.Tx, action string, actor string, before, after *model.Book) error {
+	beforeJSON, err := bookSnapshot(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := bookSnapshot(after)
+	if err != nil {
+		return err
+	}
+
+	book := after
+	changes := []byte("{}")
+	if after == nil {
+		book = before
+	} else if changes, err = diffSnapshots(beforeJSON, afterJSON); err != nil {
+		return err
+	}
+
+	var snapshot interface{}
+	if afterJSON != nil {
+		snapshot = string(afterJSON)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO book_history (book_id, version, action, actor, changes, snapshot)
+		 VALUES ($1, $2, $3, $4, $5, $6)`,
+		book.ID, book.Version, action, actor, string(changes), snapshot)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	return nil
+}
+
+// bookSnapshot сериализует книгу так же, как её отдаёт API, но без ETag
+func bookSnapshot(book *model.Book) ([]byte, error) {
+	if book == nil {
+		return nil, nil
+	}
+	snapshot := *book
+	snapshot.ETag = ""
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	return data, nil
+}
+
+// diffSnapshots сравнивает поля верхнего уровня и возвращает {"поле": {"before": ..., "after": ...}}.
+// Отсутствующее поле записывается как null.
+func diffSnapshots(before, after []byte) ([]byte, error) {
+	var beforeFields, afterFields map[string]json.RawMessage
+	if before != nil {
+		if err := json.Unmarshal(before, &beforeFields); err != nil {
+			return nil, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+		}
+	}
+	if err := json.Unmarshal(after, &afterFields); err != nil {
+		return nil, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+
+	type change struct {
+		Before json.RawMessage `json:"before"`
+		After  json.RawMessage `json:"after"`
+	}
+	changes := map[string]change{}
+	for name, value := range afterFields {
+		if !historyIgnoredFields[name] && !bytes.Equal(beforeFields[name], value) {
+			changes[name] = change{Before: nullIfMissing(beforeFields[name]), After: value}
+		}
+	}
+	for name, value := range beforeFields {
+		if _, ok := afterFields[name]; !ok && !historyIgnoredFields[name] {
+			changes[name] = change{Before: value, After: nullIfMissing(nil)}
+		}
+	}
+
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	return data, nil
+}
+
+// linkedBooks читает книги, id которых выбирает запрос link с параметром $1, включая книги
+// в корзине, в порядке id. lock дописывается к запросу, например " FOR UPDATE".
+func linkedBooks(ctx context.Context, tx *sql.Tx, link string, id string, lock string) ([]model.Book, error) {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT `+bookColumns+` FROM books WHERE id IN (`+link+`) ORDER BY id`+lock, id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	defer rows.Close()
+
+	var books []model.Book
+	for rows.Next() {
+		var book model.Book
+		if err = scanBook(rows, &book); err != nil {
+			return nil, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+		}
+		books = append(books, book)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	rows.Close()
+
+	if err = loadBookRelations(ctx, tx, books); err != nil {
+		return nil, err
+	}
+	return books, nil
+}
+
+// writeLinkedHistory записывает изменение книг, которые затронуло изменение автора или рубрики.
+// before и after - результаты linkedBooks до и после изменения.
+func writeLinkedHistory(ctx context.Context, tx *sql.Tx, actor string, before, after []model.Book) error {
+	previous := make(map[string]*model.Book, len(before))
+	for i := range before {
+		previous[before[i].ID] = &before[i]
+	}
+	for i := range after {
+		if err := writeHistory(ctx, tx, model.BookActionUpdate, actor, previous[after[i].ID], &after[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func nullIfMissing(value json.RawMessage) json.RawMessage {
+	if value == nil {
+		return json.RawMessage("null")
+	}
+	return value
+}
+
+// GetBookHistory возвращает страницу журнала изменений книги, в том числе удалённой.
+// ErrNotFound - если книги нет и записей о ней тоже нет.
+func (r *BookRepository) GetBookHistory(ctx context.Context, id string, params *query.Params) ([]model.BookHistoryEntry, int, error) {
+	whereClause := []string{"book_id = $1"}
+	args := []interface{}{id}
+	argCount := 2
+
+	for key, value := range params.Filter {
+		whereClause = append(whereClause, fmt.Sprintf("%s = $%d", key, argCount))
+		args = append(args, value)
+		argCount++
+	}
+	where := " WHERE " + strings.Join(whereClause, " AND ")
+
+	var total int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM book_history"+where, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	if total == 0 {
+		if err = r.checkBookKnown(ctx, id); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	query := "SELECT " + historyColumns + " FROM book_history" + where +
+		fmt.Sprintf(" ORDER BY %s %s, id %s LIMIT $%d OFFSET $%d", params.Sort, params.Order, params.Order, argCount, argCount+1)
+	args = append(args, params.PageSize, (params.Page-1)*params.PageSize)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	defer rows.Close()
+
+	var entries []model.BookHistoryEntry
+	for rows.Next() {
+		var entry model.BookHistoryEntry
+		if err = scanHistoryEntry(rows, &entry); err != nil {
+			return nil, 0, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+
+	return entries, total, nil
+}
+
+// GetBookRevision возвращает запись журнала, после которой книга имела указанную версию.
+// Состояние книги на тот момент лежит в Snapshot.
+func (r *BookRepository) GetBookRevision(ctx context.Context, id string, version int) (*model.BookHistoryEntry, error) {
+	var entry model.BookHistoryEntry
+	err := scanHistoryEntry(r.db.QueryRowContext(ctx,
+		`SELECT `+historyColumns+` FROM book_history
+		 WHERE book_id = $1 AND version = $2 AND snapshot IS NOT NULL
+		 ORDER BY id DESC LIMIT 1`, id, version), &entry)
+
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+
+	return &entry, nil
+}
+
+func (r *BookRepository) checkBookKnown(ctx context.Context, id string) error {
+	var exists bool
+	err := r.db.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM books WHERE id = $1) OR EXISTS (SELECT 1 FROM book_history WHERE book_id = $1)",
+		id).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	if !exists {
+		return errors.ErrNotFound
+	}
+	return nil
+}
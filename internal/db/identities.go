@@ -0,0 +1,76 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"tspo_server/internal/errors"
+	"tspo_server/model"
+)
+
+type IdentityRepository struct {
+	db *sql.DB
+}
+
+func NewIdentityRepository(db *sql.DB) (*IdentityRepository, error) {
+	return &IdentityRepository{db: db}, nil
+}
+
+func (r *IdentityRepository) GetIdentity(ctx context.Context, issuer, subject string) (*model.UserIdentity, error) {
+	var identity model.UserIdentity
+	err := r.db.QueryRowContext(ctx,
+		"SELECT issuer, subject, user_id, created_at FROM user_identities WHERE issuer = $1 AND subject = $2",
+		issuer, subject).
+		Scan(&identity.Issuer, &identity.Subject, &identity.UserID, &identity.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+
+	return &identity, nil
+}
+
+// CreateUserWithIdentity в одной транзакции создаёт пользователя и связывает его с внешней
+// учётной записью. Занятое имя или email - ErrUserExists, уже существующая связь - ErrConflict;
+// в обоих случаях ничего не сохраняется.
+func (r *IdentityRepository) CreateUserWithIdentity(ctx context.Context, user *model.User, identity *model.UserIdentity) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO users (username, email, password_hash, role, status, email_verified_at)
+		 VALUES ($1, NULLIF($2, ''), $3, $4, $5, $6) RETURNING id, created_at`,
+		user.Username, user.Email, user.PasswordHash, user.Role, user.Status, user.EmailVerifiedAt).
+		Scan(&user.ID, &user.CreatedAt)
+
+	if isUniqueViolation(err) {
+		return errors.ErrUserExists
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+
+	identity.UserID = user.ID
+	err = tx.QueryRowContext(ctx,
+		"INSERT INTO user_identities (issuer, subject, user_id) VALUES ($1, $2, $3) RETURNING created_at",
+		identity.Issuer, identity.Subject, identity.UserID).
+		Scan(&identity.CreatedAt)
+
+	if isUniqueViolation(err) {
+		return errors.ErrConflict
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	return nil
+}
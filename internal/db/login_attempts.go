@@ -0,0 +1,70 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+	"tspo_server/internal/errors"
+	"tspo_server/model"
+)
+
+type LoginAttemptRepository struct {
+	db *sql.DB
+}
+
+func NewLoginAttemptRepository(db *sql.DB) (*LoginAttemptRepository, error) {
+	return &LoginAttemptRepository{db: db}, nil
+}
+
+func (r *LoginAttemptRepository) GetAttempt(ctx context.Context, key string) (*model.LoginAttempt, error) {
+	var attempt model.LoginAttempt
+	err := r.db.QueryRowContext(ctx,
+		"SELECT key, failures, window_start, locked_until FROM login_attempts WHERE key = $1", key).
+		Scan(&attempt.Key, &attempt.Failures, &attempt.WindowStart, &attempt.LockedUntil)
+
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+
+	return &attempt, nil
+}
+
+// RecordFailure увеличивает счётчик одним UPSERT, чтобы параллельные попытки
+// с разных реплик не терялись. Если окно истекло, счёт начинается заново.
+func (r *LoginAttemptRepository) RecordFailure(ctx context.Context, key string, window time.Duration) (*model.LoginAttempt, error) {
+	var attempt model.LoginAttempt
+	err := r.db.QueryRowContext(ctx,
+		`INSERT INTO login_attempts (key, failures, window_start) VALUES ($1, 1, now())
+		 ON CONFLICT (key) DO UPDATE SET
+		     failures = CASE WHEN login_attempts.window_start < now() - make_interval(secs => $2)
+		                     THEN 1 ELSE login_attempts.failures + 1 END,
+		     window_start = CASE WHEN login_attempts.window_start < now() - make_interval(secs => $2)
+		                         THEN now() ELSE login_attempts.window_start END
+		 RETURNING key, failures, window_start, locked_until`,
+		key, window.Seconds()).
+		Scan(&attempt.Key, &attempt.Failures, &attempt.WindowStart, &attempt.LockedUntil)
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	return &attempt, nil
+}
+
+func (r *LoginAttemptRepository) Lock(ctx context.Context, key string, until time.Time) error {
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE login_attempts SET locked_until = $1 WHERE key = $2", until, key)
+
+	return checkAffected(result, err)
+}
+
+func (r *LoginAttemptRepository) Reset(ctx context.Context, key string) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM login_attempts WHERE key = $1", key)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"tspo_server/internal/errors"
+	"tspo_server/model"
+)
+
+type PasswordResetRepository struct {
+	db *sql.DB
+}
+
+func NewPasswordResetRepository(db *sql.DB) (*PasswordResetRepository, error) {
+	return &PasswordResetRepository{db: db}, nil
+}
+
+func (r *PasswordResetRepository) CreateResetToken(ctx context.Context, token *model.PasswordResetToken) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(ctx,
+		"UPDATE password_reset_tokens SET used_at = now() WHERE user_id = $1 AND used_at IS NULL",
+		token.UserID); err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO password_reset_tokens (token_hash, user_id, expires_at)
+		 VALUES ($1, $2, $3) RETURNING created_at`,
+		token.TokenHash, token.UserID, token.ExpiresAt).
+		Scan(&token.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	return nil
+}
+
+func (r *PasswordResetRepository) GetResetToken(ctx context.Context, tokenHash string) (*model.PasswordResetToken, error) {
+	var token model.PasswordResetToken
+	err := r.db.QueryRowContext(ctx,
+		`SELECT token_hash, user_id, expires_at, used_at, created_at
+		 FROM password_reset_tokens WHERE token_hash = $1`, tokenHash).
+		Scan(&token.TokenHash, &token.UserID, &token.ExpiresAt, &token.UsedAt, &token.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+
+	return &token, nil
+}
+
+func (r *PasswordResetRepository) ConsumeResetToken(ctx context.Context, tokenHash string) error {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE password_reset_tokens SET used_at = now()
+		 WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now()`,
+		tokenHash)
+
+	return checkAffected(result, err)
+}
@@ -4,8 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"strings"
+	"time"
 	"tspo_server/internal/errors"
 	"tspo_server/internal/query"
 	"tspo_server/model"
@@ -19,15 +20,51 @@ func NewBookRepository(db *sql.DB) (*BookRepository, error) {
 	return &BookRepository{db: db}, nil
 }
 
+// Необязательные поля хранятся как NULL и читаются как нулевые значения
+const bookColumns = `id, title, author, COALESCE(isbn_10, ''), COALESCE(isbn_13, ''), COALESCE(publisher, ''),
+	COALESCE(publication_year, 0), COALESCE(language, ''), COALESCE(page_count, 0), COALESCE(description, ''),
+	COALESCE(edition, ''), tags, version, COALESCE(created_by, ''), created_at, updated_at, deleted_at`
+
+func scanBook(row interface{ Scan(...interface{}) error }, book *model.Book) error {
+	return row.Scan(&book.ID, &book.Title, &book.Author, &book.ISBN10, &book.ISBN13, &book.Publisher,
+		&book.PublicationYear, &book.Language, &book.PageCount, &book.Description,
+		&book.Edition, pq.Array(&book.Tags), &book.Version, &book.CreatedBy, &book.CreatedAt, &book.UpdatedAt, &book.DeletedAt)
+}
+
+// GetBooks возвращает страницу книг, не находящихся в корзине
 func (r *BookRepository) GetBooks(ctx context.Context, params *query.Params) ([]model.Book, int, error) {
+	return r.listBooks(ctx, params, "deleted_at IS NULL")
+}
+
+// GetDeletedBooks возвращает страницу книг из корзины
+func (r *BookRepository) GetDeletedBooks(ctx context.Context, params *query.Params) ([]model.Book, int, error) {
+	return r.listBooks(ctx, params, "deleted_at IS NOT NULL")
+}
+
+func (r *BookRepository) listBooks(ctx context.Context, params *query.Params, scope string) ([]model.Book, int, error) {
 	// Build the query with filters
-	whereClause := []string{}
+	whereClause := []string{scope}
 	args := []interface{}{}
 	argCount := 1
 
 	for key, value := range params.Filter {
-		whereClause = append(whereClause, fmt.Sprintf("%s ILIKE $%d", key, argCount))
-		args = append(args, "%"+value+"%")
+		switch key {
+		case "author_id":
+			whereClause = append(whereClause, fmt.Sprintf("id IN (SELECT book_id FROM book_authors WHERE author_id = $%d)", argCount))
+			args = append(args, value)
+		case "category":
+			categories := fmt.Sprintf("$%d", argCount)
+			if params.Filter["include_descendants"] == "true" {
+				categories = fmt.Sprintf(categoryTreeSQL, argCount)
+			}
+			whereClause = append(whereClause, "id IN (SELECT book_id FROM book_categories WHERE category_id IN ("+categories+"))")
+			args = append(args, value)
+		case "include_descendants":
+			continue // уточняет фильтр category
+		default:
+			whereClause = append(whereClause, fmt.Sprintf("%s ILIKE $%d", key, argCount))
+			args = append(args, "%"+value+"%")
+		}
 		argCount++
 	}
 
@@ -42,7 +79,7 @@ func (r *BookRepository) GetBooks(ctx context.Context, params *query.Params) ([]
 		return nil, 0, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
 	}
 
-	query := "SELECT id, title, author FROM books"
+	query := "SELECT " + bookColumns + " FROM books"
 	if len(whereClause) > 0 {
 		query += " WHERE " + strings.Join(whereClause, " AND ")
 	}
@@ -62,19 +99,41 @@ func (r *BookRepository) GetBooks(ctx context.Context, params *query.Params) ([]
 	var books []model.Book
 	for rows.Next() {
 		var book model.Book
-		if err = rows.Scan(&book.ID, &book.Title, &book.Author); err != nil {
+		if err = scanBook(rows, &book); err != nil {
 			return nil, 0, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
 		}
 		books = append(books, book)
 	}
+	if err = rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+
+	if err = loadBookRelations(ctx, r.db, books); err != nil {
+		return nil, 0, err
+	}
 
 	return books, total, nil
 }
 
 func (r *BookRepository) GetBook(ctx context.Context, id string) (*model.Book, error) {
+	return getBook(ctx, r.db, "SELECT "+bookColumns+" FROM books WHERE id = $1 AND deleted_at IS NULL", id)
+}
+
+// GetBookWithDeleted возвращает книгу, даже если она в корзине
+func (r *BookRepository) GetBookWithDeleted(ctx context.Context, id string) (*model.Book, error) {
+	return getBook(ctx, r.db, "SELECT "+bookColumns+" FROM books WHERE id = $1", id)
+}
+
+// queryer - общее подмножество *sql.DB и *sql.Tx для чтения
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// getBook читает одну книгу вместе с авторами и рубриками
+func getBook(ctx context.Context, q queryer, query string, id string) (*model.Book, error) {
 	var book model.Book
-	err := r.db.QueryRowContext(ctx, "SELECT id, title, author FROM books WHERE id = $1", id).
-		Scan(&book.ID, &book.Title, &book.Author)
+	err := scanBook(q.QueryRowContext(ctx, query, id), &book)
 
 	if err == sql.ErrNoRows {
 		return nil, errors.ErrNotFound
@@ -83,53 +142,310 @@ func (r *BookRepository) GetBook(ctx context.Context, id string) (*model.Book, e
 		return nil, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
 	}
 
-	return &book, nil
+	books := []model.Book{book}
+	if err = loadBookRelations(ctx, q, books); err != nil {
+		return nil, err
+	}
+
+	return &books[0], nil
 }
 
+// lockBook читает книгу в транзакции с блокировкой строки до конца транзакции.
+// scope ограничивает выборку (например, только книги вне корзины). Если expectedVersion > 0
+// и не совпадает с версией книги, возвращает ErrPreconditionFailed.
+func lockBook(ctx context.Context, tx *sql.Tx, id string, scope string, expectedVersion int) (*model.Book, error) {
+	book, err := getBook(ctx, tx, "SELECT "+bookColumns+" FROM books WHERE id = $1 AND "+scope+" FOR UPDATE", id)
+	if err != nil {
+		return nil, err
+	}
+	if expectedVersion != 0 && book.Version != expectedVersion {
+		return nil, errors.ErrPreconditionFailed
+	}
+	return book, nil
+}
+
+func loadBookRelations(ctx context.Context, q queryer, books []model.Book) error {
+	if err := loadBookAuthors(ctx, q, books); err != nil {
+		return err
+	}
+	return loadBookCategories(ctx, q, books)
+}
+
+// CreateBook сохраняет книгу вместе с авторами, рубриками и записью истории в одной транзакции.
+// Автором записи истории считается book.CreatedBy.
 func (r *BookRepository) CreateBook(ctx context.Context, book *model.Book) error {
-	_, err := r.db.ExecContext(ctx,
-		"INSERT INTO books (id, title, author) VALUES ($1, $2, $3)",
-		book.ID, book.Title, book.Author)
+	if book.Tags == nil {
+		book.Tags = []string{}
+	}
+	if book.Categories == nil {
+		book.Categories = []model.BookCategory{}
+	}
 
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
 	}
+	defer tx.Rollback()
+
+	if err = resolveAuthors(ctx, tx, book); err != nil {
+		return err
+	}
+	book.Author = authorLine(book.Authors)
+
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO books (id, title, author, isbn_10, isbn_13, publisher, publication_year, language,
+		                    page_count, description, edition, tags, created_by)
+		 VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, 0), NULLIF($8, ''),
+		         NULLIF($9, 0), NULLIF($10, ''), NULLIF($11, ''), $12, NULLIF($13, ''))
+		 RETURNING version, created_at, updated_at`,
+		book.ID, book.Title, book.Author, book.ISBN10, book.ISBN13, book.Publisher, book.PublicationYear, book.Language,
+		book.PageCount, book.Description, book.Edition, pq.Array(book.Tags), book.CreatedBy).
+		Scan(&book.Version, &book.CreatedAt, &book.UpdatedAt)
+
+	if isUniqueViolation(err) {
+		return errors.ErrConflict
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+
+	if err = writeBookAuthors(ctx, tx, book); err != nil {
+		return err
+	}
+	if err = writeBookCategories(ctx, tx, book); err != nil {
+		return err
+	}
+	if err = writeHistory(ctx, tx, model.BookActionCreate, book.CreatedBy, nil, book); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
 	return nil
 }
 
-func (r *BookRepository) UpdateBook(ctx context.Context, book *model.Book) error {
-	result, err := r.db.ExecContext(ctx,
-		"UPDATE books SET title = $1, author = $2 WHERE id = $3",
-		book.Title, book.Author, book.ID)
+// bookField - изменяемый столбец книги: имя поля в JSON, выражение для SET
+// (%d заменяется номером параметра) и значение из модели
+type bookField struct {
+	name  string
+	set   string
+	value func(book *model.Book) interface{}
+}
+
+var bookFields = []bookField{
+	{"title", "title = $%d", func(b *model.Book) interface{} { return b.Title }},
+	{"author", "author = $%d", func(b *model.Book) interface{} { return b.Author }},
+	{"isbn_10", "isbn_10 = NULLIF($%d, '')", func(b *model.Book) interface{} { return b.ISBN10 }},
+	{"isbn_13", "isbn_13 = NULLIF($%d, '')", func(b *model.Book) interface{} { return b.ISBN13 }},
+	{"publisher", "publisher = NULLIF($%d, '')", func(b *model.Book) interface{} { return b.Publisher }},
+	{"publication_year", "publication_year = NULLIF($%d, 0)", func(b *model.Book) interface{} { return b.PublicationYear }},
+	{"language", "language = NULLIF($%d, '')", func(b *model.Book) interface{} { return b.Language }},
+	{"page_count", "page_count = NULLIF($%d, 0)", func(b *model.Book) interface{} { return b.PageCount }},
+	{"description", "description = NULLIF($%d, '')", func(b *model.Book) interface{} { return b.Description }},
+	{"edition", "edition = NULLIF($%d, '')", func(b *model.Book) interface{} { return b.Edition }},
+	{"tags", "tags = $%d", func(b *model.Book) interface{} { return pq.Array(b.Tags) }},
+}
 
+// IsBookField сообщает, сохраняет ли PatchBook поле книги с таким именем в JSON
+func IsBookField(name string) bool {
+	if name == "authors" || name == "categories" {
+		return true
+	}
+	for _, field := range bookFields {
+		if field.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// UpdateBook перезаписывает все изменяемые поля книги, её авторов и рубрики.
+// После обновления book содержит актуальное состояние записи, включая created_by и created_at.
+// Если expectedVersion > 0, запись меняется, только если её версия совпадает, иначе ErrPreconditionFailed.
+// actor попадает в историю изменений.
+func (r *BookRepository) UpdateBook(ctx context.Context, book *model.Book, expectedVersion int, actor string) error {
+	fields := []string{"authors", "categories"}
+	for _, field := range bookFields {
+		fields = append(fields, field.name)
+	}
+	return r.PatchBook(ctx, book, fields, expectedVersion, actor)
+}
+
+// PatchBook сохраняет только перечисленные поля книги (имена как в JSON), обновляет
+// updated_at и увеличивает version. Если изменилась только строка author, авторы заново
+// разбираются из неё. expectedVersion и actor работают так же, как в UpdateBook.
+// Если среди fields нет сохраняемых полей, книга не меняется и book получает её текущее состояние.
+func (r *BookRepository) PatchBook(ctx context.Context, book *model.Book, fields []string, expectedVersion int, actor string) error {
+	changed := make(map[string]bool, len(fields))
+	persistable := false
+	for _, field := range fields {
+		changed[field] = true
+		persistable = persistable || IsBookField(field)
+	}
+	if book.Tags == nil {
+		book.Tags = []string{}
+	}
+	if book.Categories == nil {
+		book.Categories = []model.BookCategory{}
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
 	}
+	defer tx.Rollback()
 
-	rows, err := result.RowsAffected()
+	before, err := lockBook(ctx, tx, book.ID, "deleted_at IS NULL", expectedVersion)
+	if err != nil {
+		return err
+	}
+	if !persistable {
+		*book = *before
+		return nil
+	}
+
+	authorsChanged := changed["author"] || changed["authors"]
+	if authorsChanged {
+		if !changed["authors"] {
+			book.Authors = nil
+		}
+		if err = resolveAuthors(ctx, tx, book); err != nil {
+			return err
+		}
+		book.Author = authorLine(book.Authors)
+		changed["author"] = true
+	}
+
+	sets := []string{}
+	args := []interface{}{}
+	for _, field := range bookFields {
+		if changed[field.name] {
+			args = append(args, field.value(book))
+			sets = append(sets, fmt.Sprintf(field.set, len(args)))
+		}
+	}
+	sets = append(sets, "updated_at = now()", "version = version + 1")
+	args = append(args, book.ID)
+
+	err = scanBook(tx.QueryRowContext(ctx,
+		fmt.Sprintf("UPDATE books SET %s WHERE id = $%d RETURNING %s", strings.Join(sets, ", "), len(args), bookColumns),
+		args...), book)
 	if err != nil {
 		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
 	}
-	if rows == 0 {
-		return errors.ErrNotFound
+
+	if authorsChanged {
+		if err = writeBookAuthors(ctx, tx, book); err != nil {
+			return err
+		}
+	} else {
+		book.Authors = before.Authors
+	}
+	if changed["categories"] {
+		if err = writeBookCategories(ctx, tx, book); err != nil {
+			return err
+		}
+	} else {
+		book.Categories = before.Categories
+	}
+	if err = writeHistory(ctx, tx, model.BookActionUpdate, actor, before, book); err != nil {
+		return err
 	}
 
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
 	return nil
 }
 
-func (r *BookRepository) DeleteBook(ctx context.Context, id string) error {
-	result, err := r.db.ExecContext(ctx, "DELETE FROM books WHERE id = $1", id)
+// DeleteBook перемещает книгу в корзину; expectedVersion и actor работают так же, как в UpdateBook
+func (r *BookRepository) DeleteBook(ctx context.Context, id string, expectedVersion int, actor string) error {
+	_, err := r.changeDeletedAt(ctx, id, "deleted_at IS NULL", "now()", expectedVersion, model.BookActionDelete, actor)
+	return err
+}
+
+// RestoreBook возвращает книгу из корзины и увеличивает её версию
+func (r *BookRepository) RestoreBook(ctx context.Context, id string, actor string) (*model.Book, error) {
+	return r.changeDeletedAt(ctx, id, "deleted_at IS NOT NULL", "NULL", 0, model.BookActionRestore, actor)
+}
+
+// changeDeletedAt перемещает книгу в корзину или из неё и записывает это в историю
+func (r *BookRepository) changeDeletedAt(ctx context.Context, id string, scope string, deletedAt string,
+	expectedVersion int, action string, actor string) (*model.Book, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	defer tx.Rollback()
+
+	before, err := lockBook(ctx, tx, id, scope, expectedVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	var book model.Book
+	err = scanBook(tx.QueryRowContext(ctx,
+		`UPDATE books SET deleted_at = `+deletedAt+`, updated_at = now(), version = version + 1
+		 WHERE id = $1
+		 RETURNING `+bookColumns, id), &book)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	book.Authors = before.Authors
+	book.Categories = before.Categories
+
+	if err = writeHistory(ctx, tx, action, actor, before, &book); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	return &book, nil
+}
+
+// PurgeBook удаляет книгу безвозвратно, в том числе из корзины. История книги сохраняется.
+func (r *BookRepository) PurgeBook(ctx context.Context, id string, expectedVersion int, actor string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
 	}
+	defer tx.Rollback()
 
-	rows, err := result.RowsAffected()
+	before, err := lockBook(ctx, tx, id, "true", expectedVersion)
 	if err != nil {
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM books WHERE id = $1", id); err != nil {
 		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
 	}
-	if rows == 0 {
-		return errors.ErrNotFound
+	if err = writeHistory(ctx, tx, model.BookActionPurge, actor, before, nil); err != nil {
+		return err
 	}
 
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
 	return nil
 }
+
+// PurgeDeletedBefore безвозвратно удаляет книги, которые лежат в корзине дольше срока хранения,
+// и в том же запросе записывает удаление в историю от имени actor
+func (r *BookRepository) PurgeDeletedBefore(ctx context.Context, cutoff time.Time, actor string) (int64, error) {
+	result, err := r.db.ExecContext(ctx,
+		`WITH purged AS (DELETE FROM books WHERE deleted_at < $1 RETURNING id, version)
+		 INSERT INTO book_history (book_id, version, action, actor)
+		 SELECT id, version, $2, $3 FROM purged`,
+		cutoff, model.BookActionPurge, actor)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	return rows, nil
+}
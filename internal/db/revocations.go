@@ -0,0 +1,53 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+	"tspo_server/internal/errors"
+)
+
+type RevocationRepository struct {
+	db *sql.DB
+}
+
+func NewRevocationRepository(db *sql.DB) (*RevocationRepository, error) {
+	return &RevocationRepository{db: db}, nil
+}
+
+func (r *RevocationRepository) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		"INSERT INTO revoked_tokens (jti, expires_at) VALUES ($1, $2) ON CONFLICT (jti) DO NOTHING",
+		jti, expiresAt)
+
+	if err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	return nil
+}
+
+func (r *RevocationRepository) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	var revoked bool
+	err := r.db.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1 AND expires_at > now())", jti).
+		Scan(&revoked)
+
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	return revoked, nil
+}
+
+func (r *RevocationRepository) PurgeExpired(ctx context.Context, now time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM revoked_tokens WHERE expires_at <= $1", now)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	return rows, nil
+}
@@ -0,0 +1,145 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+	"tspo_server/internal/errors"
+	"tspo_server/model"
+)
+
+type SessionRepository struct {
+	db *sql.DB
+}
+
+func NewSessionRepository(db *sql.DB) (*SessionRepository, error) {
+	return &SessionRepository{db: db}, nil
+}
+
+const sessionColumns = "id, user_id, user_agent, ip_address, created_at, last_used_at, expires_at, revoked_at"
+
+func scanSession(row interface{ Scan(...interface{}) error }, session *model.Session) error {
+	return row.Scan(&session.ID, &session.UserID, &session.UserAgent, &session.IPAddress,
+		&session.CreatedAt, &session.LastUsedAt, &session.ExpiresAt, &session.RevokedAt)
+}
+
+func (r *SessionRepository) CreateSession(ctx context.Context, session *model.Session) error {
+	err := r.db.QueryRowContext(ctx,
+		`INSERT INTO sessions (id, user_id, user_agent, ip_address, expires_at)
+		 VALUES ($1, $2, $3, $4, $5) RETURNING created_at, last_used_at`,
+		session.ID, session.UserID, session.UserAgent, session.IPAddress, session.ExpiresAt).
+		Scan(&session.CreatedAt, &session.LastUsedAt)
+
+	if err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	return nil
+}
+
+func (r *SessionRepository) GetSession(ctx context.Context, id string) (*model.Session, error) {
+	var session model.Session
+	err := scanSession(r.db.QueryRowContext(ctx,
+		"SELECT "+sessionColumns+" FROM sessions WHERE id = $1", id), &session)
+
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+
+	return &session, nil
+}
+
+func (r *SessionRepository) ListUserSessions(ctx context.Context, userID int64) ([]model.Session, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+sessionColumns+` FROM sessions
+		 WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > now()
+		 ORDER BY last_used_at DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	defer rows.Close()
+
+	var sessions []model.Session
+	for rows.Next() {
+		var session model.Session
+		if err = scanSession(rows, &session); err != nil {
+			return nil, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, nil
+}
+
+func (r *SessionRepository) TouchSession(ctx context.Context, id, ipAddress, userAgent string, expiresAt time.Time) error {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE sessions SET ip_address = $1, user_agent = $2, last_used_at = now(), expires_at = $3
+		 WHERE id = $4`,
+		ipAddress, userAgent, expiresAt, id)
+
+	return checkAffected(result, err)
+}
+
+func (r *SessionRepository) RevokeSession(ctx context.Context, id string) error {
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE sessions SET revoked_at = COALESCE(revoked_at, now()) WHERE id = $1", id)
+
+	return checkAffected(result, err)
+}
+
+func (r *SessionRepository) RevokeUserSessions(ctx context.Context, userID int64) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE sessions SET revoked_at = now() WHERE user_id = $1 AND revoked_at IS NULL", userID)
+
+	if err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	return nil
+}
+
+func (r *SessionRepository) SaveRefreshToken(ctx context.Context, token *model.RefreshToken) error {
+	err := r.db.QueryRowContext(ctx,
+		"INSERT INTO refresh_tokens (jti, session_id, expires_at) VALUES ($1, $2, $3) RETURNING created_at",
+		token.ID, token.SessionID, token.ExpiresAt).
+		Scan(&token.CreatedAt)
+
+	if err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	return nil
+}
+
+// ConsumeRefreshToken помечает токен использованным одним UPDATE, поэтому
+// из двух параллельных запросов с одним токеном успешным будет только один.
+func (r *SessionRepository) ConsumeRefreshToken(ctx context.Context, id string) (*model.RefreshToken, error) {
+	var token model.RefreshToken
+	err := r.db.QueryRowContext(ctx,
+		`UPDATE refresh_tokens SET used_at = now()
+		 WHERE jti = $1 AND used_at IS NULL
+		 RETURNING jti, session_id, expires_at, used_at, created_at`, id).
+		Scan(&token.ID, &token.SessionID, &token.ExpiresAt, &token.UsedAt, &token.CreatedAt)
+
+	if err == nil {
+		return &token, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+
+	// Токен либо не существует, либо уже был использован
+	err = r.db.QueryRowContext(ctx,
+		"SELECT jti, session_id, expires_at, used_at, created_at FROM refresh_tokens WHERE jti = $1", id).
+		Scan(&token.ID, &token.SessionID, &token.ExpiresAt, &token.UsedAt, &token.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+
+	return &token, errors.ErrTokenReused
+}
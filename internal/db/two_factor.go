@@ -0,0 +1,97 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"tspo_server/internal/errors"
+	"tspo_server/model"
+)
+
+type TwoFactorRepository struct {
+	db *sql.DB
+}
+
+func NewTwoFactorRepository(db *sql.DB) (*TwoFactorRepository, error) {
+	return &TwoFactorRepository{db: db}, nil
+}
+
+func (r *TwoFactorRepository) GetTOTP(ctx context.Context, userID int64) (*model.UserTOTP, error) {
+	var totp model.UserTOTP
+	err := r.db.QueryRowContext(ctx,
+		"SELECT user_id, secret, last_step, confirmed_at, created_at FROM user_totp WHERE user_id = $1", userID).
+		Scan(&totp.UserID, &totp.Secret, &totp.LastStep, &totp.ConfirmedAt, &totp.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+
+	return &totp, nil
+}
+
+// SaveTOTPSecret не перезаписывает подтверждённый секрет: в этом случае
+// ни одна строка не изменится и вернётся ErrNotFound
+func (r *TwoFactorRepository) SaveTOTPSecret(ctx context.Context, userID int64, secret string) error {
+	result, err := r.db.ExecContext(ctx,
+		`INSERT INTO user_totp (user_id, secret) VALUES ($1, $2)
+		 ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, last_step = 0, created_at = now()
+		 WHERE user_totp.confirmed_at IS NULL`,
+		userID, secret)
+
+	return checkAffected(result, err)
+}
+
+func (r *TwoFactorRepository) ConfirmTOTP(ctx context.Context, userID int64, step int64, recoveryCodeHashes []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		`UPDATE user_totp SET confirmed_at = now(), last_step = $1
+		 WHERE user_id = $2 AND confirmed_at IS NULL`,
+		step, userID)
+	if err = checkAffected(result, err); err != nil {
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id = $1", userID); err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	for _, hash := range recoveryCodeHashes {
+		if _, err = tx.ExecContext(ctx,
+			"INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)", userID, hash); err != nil {
+			return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	return nil
+}
+
+func (r *TwoFactorRepository) UseTOTPStep(ctx context.Context, userID int64, step int64) error {
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE user_totp SET last_step = $1 WHERE user_id = $2 AND last_step < $1",
+		step, userID)
+
+	err = checkAffected(result, err)
+	if err == errors.ErrNotFound {
+		return errors.ErrTokenReused
+	}
+	return err
+}
+
+func (r *TwoFactorRepository) UseRecoveryCode(ctx context.Context, userID int64, codeHash string) error {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE recovery_codes SET used_at = now()
+		 WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`,
+		userID, codeHash)
+
+	return checkAffected(result, err)
+}
@@ -0,0 +1,165 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"tspo_server/internal/errors"
+	"tspo_server/internal/query"
+	"tspo_server/model"
+)
+
+type UserRepository struct {
+	db *sql.DB
+}
+
+func NewUserRepository(db *sql.DB) (*UserRepository, error) {
+	return &UserRepository{db: db}, nil
+}
+
+const userColumns = "id, username, COALESCE(email, ''), password_hash, role, status, email_verified_at, created_at"
+
+func scanUser(row interface{ Scan(...interface{}) error }, user *model.User) error {
+	return row.Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash, &user.Role,
+		&user.Status, &user.EmailVerifiedAt, &user.CreatedAt)
+}
+
+func (r *UserRepository) CreateUser(ctx context.Context, user *model.User) error {
+	if user.Status == "" {
+		user.Status = model.UserStatusActive
+	}
+	err := r.db.QueryRowContext(ctx,
+		`INSERT INTO users (username, email, password_hash, role, status)
+		 VALUES ($1, NULLIF($2, ''), $3, $4, $5) RETURNING id, created_at`,
+		user.Username, user.Email, user.PasswordHash, user.Role, user.Status).
+		Scan(&user.ID, &user.CreatedAt)
+
+	if isUniqueViolation(err) {
+		return errors.ErrUserExists
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	return nil
+}
+
+func (r *UserRepository) GetUserByUsername(ctx context.Context, username string) (*model.User, error) {
+	return r.getUser(ctx, "SELECT "+userColumns+" FROM users WHERE username = $1", username)
+}
+
+func (r *UserRepository) GetUserByID(ctx context.Context, id int64) (*model.User, error) {
+	return r.getUser(ctx, "SELECT "+userColumns+" FROM users WHERE id = $1", id)
+}
+
+func (r *UserRepository) GetUserByEmail(ctx context.Context, email string) (*model.User, error) {
+	return r.getUser(ctx, "SELECT "+userColumns+" FROM users WHERE lower(email) = lower($1)", email)
+}
+
+func (r *UserRepository) getUser(ctx context.Context, query string, arg interface{}) (*model.User, error) {
+	var user model.User
+	err := scanUser(r.db.QueryRowContext(ctx, query, arg), &user)
+
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+
+	return &user, nil
+}
+
+// ListUsers возвращает страницу пользователей. username и email ищутся по подстроке,
+// role и status сравниваются точно.
+func (r *UserRepository) ListUsers(ctx context.Context, params *query.Params) ([]model.User, int, error) {
+	whereClause := []string{}
+	args := []interface{}{}
+	argCount := 1
+
+	for key, value := range params.Filter {
+		switch key {
+		case "username", "email":
+			whereClause = append(whereClause, fmt.Sprintf("%s ILIKE $%d", key, argCount))
+			args = append(args, "%"+value+"%")
+		default:
+			whereClause = append(whereClause, fmt.Sprintf("%s = $%d", key, argCount))
+			args = append(args, value)
+		}
+		argCount++
+	}
+
+	where := ""
+	if len(whereClause) > 0 {
+		where = " WHERE " + strings.Join(whereClause, " AND ")
+	}
+
+	var total int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users"+where, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+
+	query := "SELECT " + userColumns + " FROM users" + where +
+		fmt.Sprintf(" ORDER BY %s %s LIMIT $%d OFFSET $%d", params.Sort, params.Order, argCount, argCount+1)
+	args = append(args, params.PageSize, (params.Page-1)*params.PageSize)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	defer rows.Close()
+
+	var users []model.User
+	for rows.Next() {
+		var user model.User
+		if err = scanUser(rows, &user); err != nil {
+			return nil, 0, fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+		}
+		users = append(users, user)
+	}
+
+	return users, total, nil
+}
+
+// UpdateUser сохраняет изменяемые администратором поля: роль и статус
+func (r *UserRepository) UpdateUser(ctx context.Context, user *model.User) error {
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE users SET role = $1, status = $2 WHERE id = $3",
+		user.Role, user.Status, user.ID)
+
+	return checkAffected(result, err)
+}
+
+func (r *UserRepository) UpdatePasswordHash(ctx context.Context, id int64, hash string) error {
+	result, err := r.db.ExecContext(ctx, "UPDATE users SET password_hash = $1 WHERE id = $2", hash, id)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrDatabaseOperation, err)
+	}
+	if rows == 0 {
+		return errors.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *UserRepository) UpdateUserRole(ctx context.Context, id int64, role string) error {
+	result, err := r.db.ExecContext(ctx, "UPDATE users SET role = $1 WHERE id = $2", role, id)
+
+	return checkAffected(result, err)
+}
+
+func (r *UserRepository) MarkEmailVerified(ctx context.Context, id int64) error {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE users SET email_verified_at = now(),
+		     status = CASE WHEN status = $1 THEN $2 ELSE status END
+		 WHERE id = $3`,
+		model.UserStatusPending, model.UserStatusActive, id)
+
+	return checkAffected(result, err)
+}
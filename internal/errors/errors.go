@@ -3,20 +3,67 @@ package errors
 import "errors"
 
 var (
-	ErrNotFound          = errors.New("resource not found")
-	ErrInvalidInput      = errors.New("invalid input")
-	ErrDatabaseOperation = errors.New("database operation failed")
-	ErrTimeout           = errors.New("operation timed out")
+	ErrNotFound           = errors.New("resource not found")
+	ErrInvalidInput       = errors.New("invalid input")
+	ErrDatabaseOperation  = errors.New("database operation failed")
+	ErrTimeout            = errors.New("operation timed out")
+	ErrUserExists         = errors.New("user already exists")
+	ErrTokenReused        = errors.New("refresh token already used")
+	ErrConflict           = errors.New("resource already exists")
+	ErrPreconditionFailed = errors.New("resource version does not match")
 )
 
 type APIError struct {
-	Code    int    `json:"code"`
+	Code      int          `json:"code"`
+	ErrorCode string       `json:"error_code,omitempty"` // машиночитаемая причина, когда HTTP-кода недостаточно
+	Message   string       `json:"message"`
+	Details   []FieldError `json:"details,omitempty"`
+}
+
+// FieldError описывает ошибку валидации конкретного поля запроса
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
 	Message string `json:"message"`
 }
 
+// FieldValidationError - ошибка в данных запроса, обнаруженная только при записи
+// (например, ссылка на несуществующую запись). Подходит под errors.Is(err, ErrInvalidInput).
+type FieldValidationError struct {
+	Field FieldError
+}
+
+func NewFieldValidationError(field, code, message string) *FieldValidationError {
+	return &FieldValidationError{Field: FieldError{Field: field, Code: code, Message: message}}
+}
+
+func (e *FieldValidationError) Error() string {
+	return e.Field.Field + ": " + e.Field.Message
+}
+
+func (e *FieldValidationError) Unwrap() error {
+	return ErrInvalidInput
+}
+
 func NewAPIError(code int, message string) *APIError {
 	return &APIError{
 		Code:    code,
 		Message: message,
 	}
 }
+
+func NewCodedAPIError(code int, errorCode, message string) *APIError {
+	return &APIError{
+		Code:      code,
+		ErrorCode: errorCode,
+		Message:   message,
+	}
+}
+
+func NewValidationAPIError(code int, message string, details []FieldError) *APIError {
+	return &APIError{
+		Code:    code,
+		Message: message,
+		Details: details,
+	}
+}
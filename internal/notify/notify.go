@@ -0,0 +1,98 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// Message - уведомление пользователю (ссылка сброса пароля, подтверждение email и т.п.)
+type Message struct {
+	To      string    `json:"to"`
+	Subject string    `json:"subject"`
+	Body    string    `json:"body"`
+	SentAt  time.Time `json:"sent_at"`
+}
+
+// Notifier доставляет уведомления. Реализации для локальной разработки
+// не отправляют сообщения наружу, а пишут их в лог или файл.
+type Notifier interface {
+	Notify(ctx context.Context, msg Message) error
+}
+
+type Config struct {
+	Kind         string // log, file, smtp
+	File         string
+	SMTPAddr     string // host:port
+	SMTPFrom     string
+	SMTPUsername string
+	SMTPPassword string
+}
+
+// New выбирает реализацию по Config.Kind
+func New(cfg Config, logger *slog.Logger) (Notifier, error) {
+	switch cfg.Kind {
+	case "", "log":
+		return NewLogNotifier(logger), nil
+	case "file":
+		return NewFileNotifier(cfg.File), nil
+	case "smtp":
+		if cfg.SMTPAddr == "" || cfg.SMTPFrom == "" {
+			return nil, fmt.Errorf("smtp notifier requires address and sender")
+		}
+		return NewSMTPNotifier(cfg.SMTPAddr, cfg.SMTPFrom, cfg.SMTPUsername, cfg.SMTPPassword), nil
+	}
+	return nil, fmt.Errorf("unsupported notifier: %q", cfg.Kind)
+}
+
+type LogNotifier struct {
+	logger *slog.Logger
+}
+
+func NewLogNotifier(logger *slog.Logger) *LogNotifier {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &LogNotifier{logger: logger}
+}
+
+func (n *LogNotifier) Notify(ctx context.Context, msg Message) error {
+	n.logger.Info("notification", "to", msg.To, "subject", msg.Subject, "body", msg.Body)
+	return nil
+}
+
+// FileNotifier дописывает уведомления в файл по одному JSON-объекту на строку
+type FileNotifier struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileNotifier(path string) *FileNotifier {
+	return &FileNotifier{path: path}
+}
+
+func (n *FileNotifier) Notify(ctx context.Context, msg Message) error {
+	if msg.SentAt.IsZero() {
+		msg.SentAt = time.Now()
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	f, err := os.OpenFile(n.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
@@ -13,7 +13,24 @@ type Params struct {
 	Filter   map[string]string
 }
 
+// Options описывает, какие параметры списка допустимы для конкретного ресурса
+type Options struct {
+	DefaultSort string
+	Sortable    []string // поля, по которым разрешена сортировка; подставляются в SQL как есть
+	Filters     []string // параметры запроса, которые попадают в Filter
+}
+
+var bookOptions = Options{
+	DefaultSort: "title",
+	Sortable:    []string{"id", "title", "author", "publisher", "publication_year", "created_at", "updated_at"},
+	Filters:     []string{"title", "author", "author_id", "category", "include_descendants"},
+}
+
 func NewParams(r *http.Request) *Params {
+	return NewParamsWithOptions(r, bookOptions)
+}
+
+func NewParamsWithOptions(r *http.Request, opts Options) *Params {
 	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
 	if page < 1 {
 		page = 1
@@ -25,8 +42,8 @@ func NewParams(r *http.Request) *Params {
 	}
 
 	sort := r.URL.Query().Get("sort")
-	if sort == "" {
-		sort = "title"
+	if !contains(opts.Sortable, sort) {
+		sort = opts.DefaultSort
 	}
 
 	order := r.URL.Query().Get("order")
@@ -35,11 +52,10 @@ func NewParams(r *http.Request) *Params {
 	}
 
 	filter := make(map[string]string)
-	if title := r.URL.Query().Get("title"); title != "" {
-		filter["title"] = title
-	}
-	if author := r.URL.Query().Get("author"); author != "" {
-		filter["author"] = author
+	for _, key := range opts.Filters {
+		if value := r.URL.Query().Get(key); value != "" {
+			filter[key] = value
+		}
 	}
 
 	return &Params{
@@ -50,3 +66,12 @@ func NewParams(r *http.Request) *Params {
 		Filter:   filter,
 	}
 }
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
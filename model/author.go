@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+const (
+	AuthorRoleAuthor     = "author"
+	AuthorRoleEditor     = "editor"
+	AuthorRoleTranslator = "translator"
+)
+
+type Author struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Bio       string    `json:"bio,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// BookAuthor - участник издания. Порядок в Book.Authors совпадает с порядком на обложке.
+// При записи книги достаточно id или имени: неизвестные имена создаются как новые авторы.
+type BookAuthor struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+	Role string `json:"role"` // author, editor, translator
+}
@@ -1,7 +1,26 @@
 package model
 
+import "time"
+
 type Book struct {
-	ID     string `json:"id"`
-	Title  string `json:"title"`
-	Author string `json:"author"`
+	ID              string         `json:"id"`
+	Title           string         `json:"title"`
+	Author          string         `json:"author"` // имена авторов через запятую, пересчитывается по Authors
+	Authors         []BookAuthor   `json:"authors"`
+	ISBN10          string         `json:"isbn_10,omitempty"`
+	ISBN13          string         `json:"isbn_13,omitempty"`
+	Publisher       string         `json:"publisher,omitempty"`
+	PublicationYear int            `json:"publication_year,omitempty"`
+	Language        string         `json:"language,omitempty"` // код языка, например "en" или "ru"
+	PageCount       int            `json:"page_count,omitempty"`
+	Description     string         `json:"description,omitempty"`
+	Edition         string         `json:"edition,omitempty"`
+	Tags            []string       `json:"tags"`
+	Categories      []BookCategory `json:"categories"`
+	Version         int            `json:"version"`              // увеличивается при каждом изменении
+	ETag            string         `json:"etag,omitempty"`       // заполняется сервером по Version
+	CreatedBy       string         `json:"created_by,omitempty"` // заполняется сервером из claims
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       *time.Time     `json:"deleted_at,omitempty"` // задано у книг в корзине
 }
@@ -0,0 +1,28 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	BookActionCreate  = "create"
+	BookActionUpdate  = "update"
+	BookActionDelete  = "delete"  // перемещение в корзину
+	BookActionRestore = "restore" // возврат из корзины
+	BookActionPurge   = "purge"   // безвозвратное удаление
+)
+
+// BookHistoryEntry - запись журнала изменений книги. Changes содержит изменённые поля
+// в виде {"поле": {"before": ..., "after": ...}}, Snapshot - книгу после изменения
+// (для purge отсутствует).
+type BookHistoryEntry struct {
+	ID        int64           `json:"id"`
+	BookID    string          `json:"book_id"`
+	Version   int             `json:"version"`
+	Action    string          `json:"action"`
+	Actor     string          `json:"actor"`
+	ChangedAt time.Time       `json:"changed_at"`
+	Changes   json.RawMessage `json:"changes"`
+	Snapshot  json.RawMessage `json:"snapshot,omitempty"`
+}
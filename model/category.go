@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// Category - узел иерархического рубрикатора, например
+// Computer Science > Programming Languages > Go
+type Category struct {
+	ID          string    `json:"id"`
+	ParentID    string    `json:"parent_id,omitempty"` // пустой у корневых рубрик
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// BookCategory - рубрика в составе книги. При записи книги достаточно id.
+type BookCategory struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+}
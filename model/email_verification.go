@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// EmailVerificationToken - одноразовый токен подтверждения email. Хранится только хэш токена.
+type EmailVerificationToken struct {
+	TokenHash string     `json:"-"`
+	UserID    int64      `json:"user_id"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -0,0 +1,11 @@
+package model
+
+import "time"
+
+// UserIdentity связывает пользователя с учётной записью внешнего IdP (iss + sub)
+type UserIdentity struct {
+	Issuer    string    `json:"issuer"`
+	Subject   string    `json:"subject"`
+	UserID    int64     `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
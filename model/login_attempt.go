@@ -0,0 +1,11 @@
+package model
+
+import "time"
+
+// LoginAttempt - счётчик неудачных входов по ключу (имя пользователя или IP)
+type LoginAttempt struct {
+	Key         string     `json:"key"`
+	Failures    int        `json:"failures"`
+	WindowStart time.Time  `json:"window_start"`
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+}
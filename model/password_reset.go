@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// PasswordResetToken - одноразовый токен сброса пароля. Хранится только хэш токена.
+type PasswordResetToken struct {
+	TokenHash string     `json:"-"`
+	UserID    int64      `json:"user_id"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// Session - одно устройство/логин пользователя. Все refresh-токены, полученные
+// ротацией от одного логина, принадлежат одной сессии (семейству токенов).
+type Session struct {
+	ID         string     `json:"id"`
+	UserID     int64      `json:"user_id"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+type RefreshToken struct {
+	ID        string     `json:"id"` // jti
+	SessionID string     `json:"session_id"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
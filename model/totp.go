@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// UserTOTP - секрет TOTP пользователя. Пока ConfirmedAt == nil, настройка
+// не завершена и второй фактор при входе не запрашивается.
+type UserTOTP struct {
+	UserID      int64      `json:"user_id"`
+	Secret      string     `json:"-"`
+	LastStep    int64      `json:"-"` // последний принятый шаг, защищает от повторного использования кода
+	ConfirmedAt *time.Time `json:"confirmed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
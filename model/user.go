@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// Статусы учётной записи. Войти может только пользователь в статусе active.
+const (
+	UserStatusPending  = "pending" // email ещё не подтверждён
+	UserStatusActive   = "active"
+	UserStatusDisabled = "disabled"
+	UserStatusDeleted  = "deleted"
+)
+
+type User struct {
+	ID              int64      `json:"id"`
+	Username        string     `json:"username"`
+	Email           string     `json:"email,omitempty"`
+	PasswordHash    string     `json:"-"`
+	Role            string     `json:"role"`
+	Status          string     `json:"status"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
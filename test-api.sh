@@ -2,8 +2,18 @@
 
 API_URL="http://localhost:8080"
 
+# Изменение книг требует If-Match с текущим ETag (REQUIRE_IF_MATCH=true)
+etag_of() {
+  curl -s -o /dev/null -D - "${API_URL}$1" | tr -d '\r' | sed -n 's/^[Ee][Tt][Aa][Gg]: //p'
+}
+
 echo -e "\n \n ======Тестирование запросов по 1 практике (CRUD операции)======\n"
 
+# Изменение книг доступно только librarian/admin, поэтому входим под администратором
+admin_token=$(curl -s -X POST ${API_URL}/auth/login \
+   -H "Content-Type: application/json" \
+   -d '{"username": "admin", "password": "Admin12345"}' | jq -r '.access_token')
+
 
 echo -e "\nПолучение всех книг :\n"
 curl -s -X GET "${API_URL}/books"
@@ -13,38 +23,60 @@ echo -e "\n Получение книги по id 1:\n"
 curl -s -X GET "${API_URL}/books/1"
 sleep 2
 
-echo -e "\nСоздание новой записи книги:\n Запись {
-                             \"id\": \"5\",
+echo -e "\nСоздание новой записи книги (id генерирует сервер):\n Запись {
                              \"title\": \"The Pragmatic Programmer\",
                              \"author\": \"Andy Hunt\"
                                             }\":"
-curl -s -X POST "${API_URL}/books" \
+new_book_location=$(curl -s -o /dev/stderr -D - -X POST "${API_URL}/books" \
+  -H "Authorization: Bearer ${admin_token}" \
   -H "Content-Type: application/json" \
   -d '{
-      "id": "5",
       "title": "The Pragmatic Programmer",
       "author": "Andy Hunt"
-    }'
+    }' | tr -d '\r' | sed -n 's/^Location: //p')
+echo -e "\nLocation: ${new_book_location}"
 sleep 2
-echo -e "\nПроверка наличия книги по ID 5 перед удалением:\n"
-curl -s -X GET "${API_URL}/books"
+echo -e "\nПроверка наличия новой книги перед удалением:\n"
+curl -s -X GET "${API_URL}${new_book_location}"
 sleep 2
 
-echo -e "\nУдаление книги по ID 5:"
-curl -s -X DELETE "${API_URL}/books/5"
+echo -e "\nУдаление новой книги:"
+curl -s -X DELETE "${API_URL}${new_book_location}" \
+  -H "Authorization: Bearer ${admin_token}" \
+  -H "If-Match: $(etag_of ${new_book_location})"
 sleep 2
 
 echo -e "\nПодтверждение удаления книги: - получение всех книг::\n"
 curl -s -X GET "${API_URL}/books"
 sleep 2
 
-echo -e "\nОбновление книги по ID 3:"
+echo -e "\nПолная замена книги по ID 3 (PUT требует полное представление):"
 curl -s -X PUT "${API_URL}/books/3" \
+  -H "Authorization: Bearer ${admin_token}" \
+  -H "If-Match: $(etag_of /books/3)" \
   -d '{
-    "title": "The Pragmatic Programmer: 20th Anniversary Edition",
-    "author": "Andy Hunt and Dave Thomas"
+    "title": "Design Patterns: Elements of Reusable Object-Oriented Software",
+    "authors": [{"name": "Erich Gamma"}, {"name": "Richard Helm"}, {"name": "Ralph Johnson"}, {"name": "John Vlissides"}],
+    "tags": ["oop"],
+    "categories": []
   }'
 sleep 2
+
+echo -e "\nЧастичное обновление книги по ID 3 (JSON Merge Patch):"
+curl -s -X PATCH "${API_URL}/books/3" \
+  -H "Authorization: Bearer ${admin_token}" \
+  -H "If-Match: $(etag_of /books/3)" \
+  -H "Content-Type: application/merge-patch+json" \
+  -d '{"publication_year": 1994, "isbn_13": "978-0-201-63361-0"}'
+sleep 2
+
+echo -e "\nЧастичное обновление книги по ID 3 (JSON Patch):"
+curl -s -X PATCH "${API_URL}/books/3" \
+  -H "Authorization: Bearer ${admin_token}" \
+  -H "If-Match: $(etag_of /books/3)" \
+  -H "Content-Type: application/json-patch+json" \
+  -d '[{"op": "add", "path": "/tags/-", "value": "design-patterns"}]'
+sleep 2
 echo -e "\nПроверка обновления книги:\n"
 curl -s -X GET "${API_URL}/books"
 sleep 2
@@ -57,7 +89,17 @@ echo "Регистрация нового пользователя:"
 
 curl -X POST ${API_URL}/auth/register \
   -H "Content-Type: application/json" \
-  -d '{"username": "testuser4", "password": "testpass"}'
+  -d '{"username": "testuser4", "email": "testuser4@example.com", "password": "Testpass123"}'
+
+echo "Подтверждение email токеном из письма:"
+sleep 1
+
+verification_token=$(docker exec webapi sh -c 'grep testuser4@example.com /app/notifications.log | tail -n 1' \
+  | jq -r '.body' | grep -o '[0-9a-f]\{64\}')
+
+curl -X POST ${API_URL}/auth/verify-email \
+  -H "Content-Type: application/json" \
+  -d "{\"token\": \"${verification_token}\"}"
 
 echo "Авторизация для получение токена:"
 sleep 3
@@ -65,7 +107,7 @@ sleep 3
 
 response=$(curl -X POST ${API_URL}/auth/login \
    -H "Content-Type: application/json" \
-   -d '{"username": "testuser4", "password": "testpass"}')
+   -d '{"username": "testuser4", "password": "Testpass123"}')
 
 # Парсинг access_token из ответа
 access_token=$(echo "$response" | jq -r '.access_token')
@@ -78,6 +120,17 @@ curl -X GET http://localhost:8080/books_with_auth \
   -H "Authorization: Bearer ${access_token}"
 
 
+echo -e "\n \n ======Вход через OIDC (mock IdP)======\n"
+
+# Начало входа: сервер ставит cookie с state и перенаправляет на IdP
+idp_url=$(curl -s -o /dev/null -c /tmp/oidc_cookies -w '%{redirect_url}' ${API_URL}/auth/oidc/login)
+
+# mock IdP принимает пользователя и группы параметрами вместо формы входа
+callback_url=$(curl -s -o /dev/null -w '%{redirect_url}' "${idp_url}&username=alice&groups=librarians")
+
+curl -s -b /tmp/oidc_cookies "${callback_url}"
+
+
 echo -e "\n \n ======Тестирование запросов по 4 практике (Пагинация)======\n"
 
 echo -e "\nПолучение книг с пагинацией\n"
@@ -92,3 +145,80 @@ echo -e "\nПолучение книг с сортировкой\n"
 
 curl "http://localhost:8080/books?sort=title&order=desc"
 
+
+
+echo -e "\n \n ======Авторы======\n"
+
+echo -e "\nСписок авторов с фильтром по имени\n"
+
+curl -s "${API_URL}/authors?name=Thomas"
+
+echo -e "\nКниги первого найденного автора\n"
+
+author_id=$(curl -s "${API_URL}/authors?name=David%20Thomas" | sed -n 's/.*"id":"\([^"]*\)".*/\1/p')
+curl -s "${API_URL}/authors/${author_id}/books"
+
+
+echo -e "\n \n ======Рубрики======\n"
+
+echo -e "\nСоздание рубрик Computer Science > Programming Languages > Go\n"
+
+cs_id=$(curl -s -X POST "${API_URL}/categories" \
+  -H "Authorization: Bearer ${admin_token}" \
+  -d '{"name": "Computer Science"}' | sed -n 's/.*"data":{"id":"\([^"]*\)".*/\1/p')
+pl_id=$(curl -s -X POST "${API_URL}/categories" \
+  -H "Authorization: Bearer ${admin_token}" \
+  -d "{\"name\": \"Programming Languages\", \"parent_id\": \"${cs_id}\"}" | sed -n 's/.*"data":{"id":"\([^"]*\)".*/\1/p')
+go_id=$(curl -s -X POST "${API_URL}/categories" \
+  -H "Authorization: Bearer ${admin_token}" \
+  -d "{\"name\": \"Go\", \"parent_id\": \"${pl_id}\"}" | sed -n 's/.*"data":{"id":"\([^"]*\)".*/\1/p')
+
+echo -e "\nПривязка книги 1 к рубрике Go\n"
+
+curl -s -X PATCH "${API_URL}/books/1" \
+  -H "Authorization: Bearer ${admin_token}" \
+  -H "If-Match: $(etag_of /books/1)" \
+  -H "Content-Type: application/merge-patch+json" \
+  -d "{\"categories\": [{\"id\": \"${go_id}\"}]}"
+
+echo -e "\nКниги рубрики Computer Science вместе с подрубриками\n"
+
+curl -s "${API_URL}/books?category=${cs_id}&include_descendants=true"
+
+
+echo -e "\n \n ======Условные запросы (ETag)======\n"
+
+book1_etag=$(etag_of /books/1)
+
+echo -e "\nПовторное чтение с If-None-Match (ожидается 304):\n"
+curl -s -o /dev/null -w '%{http_code}\n' -H "If-None-Match: ${book1_etag}" "${API_URL}/books/1"
+
+echo -e "\nИзменение с устаревшим If-Match (ожидается 412):\n"
+curl -s -X PATCH "${API_URL}/books/1" \
+  -H "Authorization: Bearer ${admin_token}" \
+  -H "Content-Type: application/merge-patch+json" \
+  -H 'If-Match: "0"' \
+  -d '{"edition": "1st"}'
+
+
+echo -e "\n \n ======Корзина======\n"
+
+echo -e "\nКниги в корзине (удалённая ранее книга):\n"
+curl -s "${API_URL}/books/trash" -H "Authorization: Bearer ${admin_token}"
+
+echo -e "\nВосстановление книги из корзины:\n"
+curl -s -X POST "${API_URL}${new_book_location}/restore" -H "Authorization: Bearer ${admin_token}"
+
+echo -e "\nБезвозвратное удаление (только admin):\n"
+curl -s -o /dev/null -w '%{http_code}\n' -X DELETE "${API_URL}${new_book_location}?hard=true" \
+  -H "Authorization: Bearer ${admin_token}" \
+  -H "If-Match: $(etag_of ${new_book_location})"
+
+
+echo -e "\n \n ======История изменений======\n"
+
+echo -e "\nЖурнал изменений книги (сохраняется и после безвозвратного удаления):\n"
+curl -s "${API_URL}${new_book_location}/history?sort=version&order=desc" -H "Authorization: Bearer ${admin_token}"
+
+echo -e "\nСостояние книги в версии 1:\n"
+curl -s "${API_URL}${new_book_location}/history/1" -H "Authorization: Bearer ${admin_token}"